@@ -2,20 +2,31 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"log"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action"
+	"github.com/ww24/calendar-notifier/interface/leader"
 	"github.com/ww24/calendar-notifier/usecase"
 )
 
 type app struct {
-	h    http.Handler
-	sync usecase.Synchronizer
+	h       http.Handler
+	sync    usecase.Synchronizer
+	action  *action.Action
+	elector *leader.Elector
 }
 
-func newApp(h http.Handler, sync usecase.Synchronizer) *app {
+func newApp(h http.Handler, sync usecase.Synchronizer, action *action.Action, elector *leader.Elector) *app {
 	return &app{
-		h:    h,
-		sync: sync,
+		h:       h,
+		sync:    sync,
+		action:  action,
+		elector: elector,
 	}
 }
 
@@ -29,6 +40,40 @@ func (a *app) server(port string) *http.Server {
 	}
 }
 
+// worker campaigns for leadership and runs sync worker until ctx is done.
+// Leadership is released when the worker stops.
+// Sync lease is held by each launch request instead in ondemand mode.
 func (a *app) worker(ctx context.Context) error {
-	return a.sync.Worker(ctx)
+	if a.sync.RunningMode() != model.ModeResident {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	electorErr := make(chan error, 1)
+	go func() {
+		electorErr <- a.elector.Run(ctx)
+	}()
+	err := a.sync.Worker(ctx)
+	cancel()
+	if rerr := <-electorErr; rerr != nil {
+		log.Println("[leader] release error:", rerr)
+	}
+	return err
+}
+
+// shutdown shuts down actions and reports abandoned schedule events.
+func (a *app) shutdown(ctx context.Context, grace time.Duration, abandonedEventsFile string) error {
+	abandoned := a.action.Shutdown(ctx, grace)
+	for _, ae := range abandoned {
+		log.Printf("schedule event abandoned: action=%s, schedule_id=%s, type=%s, execute_at=%s\n",
+			ae.ActionName, ae.Event.ScheduleID, ae.Event.EventType, ae.Event.ExecuteAt.Format(time.RFC3339))
+	}
+	if abandonedEventsFile == "" || len(abandoned) == 0 {
+		return nil
+	}
+	d, err := json.MarshalIndent(abandoned, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(abandonedEventsFile, append(d, '\n'), 0o600)
 }
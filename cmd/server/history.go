@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/history"
+)
+
+const historyRequestTimeout = 10 * time.Second
+
+func showHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	confFile := fs.String("config", "", "set path to config to read file history")
+	server := fs.String("server", "", "set base URL of running server to read history, e.g. http://localhost:8080")
+	actionName := fs.String("action", "", "filter by action name")
+	limit := fs.Int("limit", 20, "set the number of the latest records (0 means all)")
+	jsonOutput := fs.Bool("json", false, "print records as JSON")
+	_ = fs.Parse(args)
+	if *confFile == "" && *server == "" {
+		fmt.Fprintln(os.Stderr, "-config or -server flag is required")
+		fmt.Fprintf(os.Stderr, "Usage of %s history:\n", os.Args[0])
+		fs.PrintDefaults()
+		os.Exit(exitError)
+	}
+
+	q := model.HistoryQuery{ActionName: model.ActionName(*actionName), Limit: *limit}
+	var (
+		records []model.Execution
+		err     error
+	)
+	if *server != "" {
+		records, err = fetchHistory(*server, q)
+	} else {
+		records, err = readHistory(*confFile, q)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "History error: %+v\n", err)
+		os.Exit(exitError)
+	}
+	if err := printHistory(records, *jsonOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "Output error: %+v\n", err)
+		os.Exit(exitError)
+	}
+}
+
+func readHistory(confFile string, q model.HistoryQuery) ([]model.Execution, error) {
+	conf := loadConfig(confFile, model.ModeNone, false)
+	hc := conf.HistoryConfig()
+	if hc.Type != model.HistoryFile {
+		return nil, fmt.Errorf("%s history is only available from running server, use -server flag", hc.Type)
+	}
+	return history.NewFile(hc.Path, hc.Size).List(q)
+}
+
+func fetchHistory(server string, q model.HistoryQuery) ([]model.Execution, error) {
+	v := url.Values{}
+	if q.ActionName != "" {
+		v.Set("action", string(q.ActionName))
+	}
+	v.Set("limit", strconv.Itoa(q.Limit))
+	cli := &http.Client{Timeout: historyRequestTimeout}
+	resp, err := cli.Get(strings.TrimSuffix(server, "/") + "/history?" + v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	res := struct {
+		History []model.Execution `json:"history"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res.History, nil
+}
+
+func printHistory(records []model.Execution, jsonOutput bool) error {
+	if jsonOutput {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(records)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED_AT\tACTION\tEVENT\tSCHEDULED_AT\tOUTCOME\tATTEMPTS\tSTATUS\tLATENCY")
+	for _, r := range records {
+		status := r.MessageID
+		if r.StatusCode != 0 {
+			status = strconv.Itoa(r.StatusCode)
+		}
+		if r.ExitCode != nil {
+			status = "exit " + strconv.Itoa(*r.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%dms\n",
+			r.StartedAt.Format(time.RFC3339), r.ActionName, r.EventID, r.ScheduledAt.Format(time.RFC3339),
+			r.Outcome, r.Attempts, status, r.LatencyMillis)
+	}
+	return w.Flush()
+}
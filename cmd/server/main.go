@@ -2,43 +2,69 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
+	"github.com/ww24/calendar-notifier/domain/model"
 	"github.com/ww24/calendar-notifier/interface/config"
 )
 
 const (
-	shutdownTimeout = 30 * time.Second
-	defaultPort     = "8080"
+	defaultPort = "8080"
 )
 
 var (
-	confFile = flag.String("config", "", "set path to config (required)")
-	port     = os.Getenv("PORT")
+	port = os.Getenv("PORT")
 )
 
 func main() {
-	flag.Parse()
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "serve":
+		serve(args)
+	case "sync":
+		syncOnce(args)
+	case "migrate":
+		migrate(args)
+	case "history":
+		showHistory(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "  serve    run calendar-notifier (default)")
+		fmt.Fprintln(os.Stderr, "  sync     synchronize schedules once and exit")
+		fmt.Fprintln(os.Stderr, "  migrate  convert config file to the latest version")
+		fmt.Fprintln(os.Stderr, "  history  show execution history of actions")
+		os.Exit(exitError)
+	}
+}
+
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	confFile := fs.String("config", "", "set path to config (required)")
+	dryRun := fs.Bool("dry-run", false, "plan schedule events without registering them to actions")
+	_ = fs.Parse(args)
 	if *confFile == "" {
 		fmt.Fprintln(os.Stderr, "-config flag is required")
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
-		flag.PrintDefaults()
-		os.Exit(1)
+		fs.PrintDefaults()
+		os.Exit(exitError)
 	}
 
-	conf, err := config.Parse(*confFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Config loading error: %+v\n", err)
-		os.Exit(1)
+	conf := loadConfig(*confFile, model.ModeNone, *dryRun)
+	if conf.Mode == model.ModeOneShot {
+		os.Exit(runOnce(conf, false, false))
 	}
-	log.Printf("Config loaded: v%s\n", conf.Version)
-	log.Println("Running mode:", conf.Mode)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -46,28 +72,73 @@ func main() {
 	app, err := initialize(ctx, conf)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Initialize Error: %+v\n", err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
+	workerCtx, stopWorker := context.WithCancel(ctx)
+	defer stopWorker()
+
 	srv := app.server(port)
+	errCh := make(chan error, 2)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			panic(err)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- fmt.Errorf("server: %w", err)
 		}
 	}()
 	go func() {
-		if err := app.worker(ctx); err != nil {
-			panic(err)
+		if err := app.worker(workerCtx); err != nil {
+			errCh <- fmt.Errorf("worker: %w", err)
 		}
 	}()
 
+	exitCode := exitOK
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
-	<-sigCh
-	cancel()
+	select {
+	case <-sigCh:
+	case err := <-errCh:
+		log.Printf("Error: %+v\n", err)
+		exitCode = exitError
+	}
+	stopWorker()
 
-	ctx, cancel = context.WithTimeout(context.Background(), shutdownTimeout)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout())
+	defer shutdownCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Shutdown Error:", err)
+	}
+	if err := app.shutdown(shutdownCtx, conf.ShutdownGraceWindow(), conf.AbandonedEventsFile()); err != nil {
 		log.Println("Shutdown Error:", err)
 	}
+	cancel()
+	if exitCode != exitOK {
+		shutdownCancel()
+		os.Exit(exitCode)
+	}
+}
+
+func loadConfig(confFile string, mode model.RunningMode, dryRun bool) *config.Config {
+	conf, err := config.Parse(confFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config loading error: %+v\n", err)
+		os.Exit(exitError)
+	}
+	if conf.SourceVersion() != conf.Version {
+		log.Printf("Config loaded: v%s (migrated to v%s, run migrate command to update the config file)\n", conf.SourceVersion(), conf.Version)
+	} else {
+		log.Printf("Config loaded: v%s\n", conf.Version)
+	}
+	if mode != model.ModeNone {
+		if err := conf.SetRunningMode(mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Config loading error: %+v\n", err)
+			os.Exit(exitError)
+		}
+	}
+	if dryRun {
+		conf.DryRun = true
+	}
+	log.Println("Running mode:", conf.Mode)
+	if conf.DryRun {
+		log.Println("Dry run: schedule events are never registered to actions")
+	}
+	return conf
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ww24/calendar-notifier/interface/config"
+)
+
+func migrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	confFile := fs.String("config", "", "set path to config (required)")
+	output := fs.String("output", "", "set path to write migrated config (default: stdout)")
+	_ = fs.Parse(args)
+	if *confFile == "" {
+		fmt.Fprintln(os.Stderr, "-config flag is required")
+		fmt.Fprintf(os.Stderr, "Usage of %s migrate:\n", os.Args[0])
+		fs.PrintDefaults()
+		os.Exit(exitError)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Output error: %+v\n", err)
+			os.Exit(exitError)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := config.Migrate(*confFile, w); err != nil {
+		fmt.Fprintf(os.Stderr, "Migration error: %+v\n", err)
+		os.Exit(exitError)
+	}
+}
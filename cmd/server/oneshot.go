@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/config"
+	"github.com/ww24/calendar-notifier/usecase"
+)
+
+// exit codes of oneshot running mode.
+const (
+	exitOK        = 0
+	exitError     = 1
+	exitSyncError = 2
+)
+
+func syncOnce(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	confFile := fs.String("config", "", "set path to config (required)")
+	dryRun := fs.Bool("dry-run", false, "plan schedule events without registering them to actions")
+	jsonOutput := fs.Bool("json", false, "print summary as JSON")
+	force := fs.Bool("force", false, "unregister events even if sync guard refuses it")
+	_ = fs.Parse(args)
+	if *confFile == "" {
+		fmt.Fprintln(os.Stderr, "-config flag is required")
+		fmt.Fprintf(os.Stderr, "Usage of %s sync:\n", os.Args[0])
+		fs.PrintDefaults()
+		os.Exit(exitError)
+	}
+
+	conf := loadConfig(*confFile, model.ModeOneShot, *dryRun)
+	os.Exit(runOnce(conf, *jsonOutput, *force))
+}
+
+// runOnce synchronizes schedules once, prints summary and returns exit code.
+func runOnce(conf *config.Config, jsonOutput, force bool) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app, err := initialize(ctx, conf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Initialize Error: %+v\n", err)
+		return exitError
+	}
+	if force {
+		ctx = usecase.WithForce(ctx)
+	}
+	report, syncErr := app.sync.Sync(ctx)
+	if report != nil {
+		// report is printed even if some of actions failed
+		if err := printReport(report, jsonOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "Output Error: %+v\n", err)
+			return exitError
+		}
+	}
+	if syncErr != nil {
+		fmt.Fprintf(os.Stderr, "Sync Error: %+v\n", syncErr)
+		return exitSyncError
+	}
+	return exitOK
+}
+
+func printReport(report *model.SyncReport, jsonOutput bool) error {
+	if jsonOutput {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(report)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tREGISTERED\tUNREGISTERED\tUNCHANGED\tEXPIRED\tFAILED")
+	for _, ar := range report.Actions {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", ar.Name, len(ar.Registered), len(ar.Unregistered), len(ar.Unchanged), len(ar.Expired), len(ar.Failed))
+	}
+	if report.DryRun {
+		fmt.Fprintln(w, "(dry run)")
+	}
+	return w.Flush()
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/ww24/calendar-notifier/domain/service"
 	"github.com/ww24/calendar-notifier/interface/action"
 	"github.com/ww24/calendar-notifier/interface/calendar"
+	"github.com/ww24/calendar-notifier/interface/history"
 	"github.com/ww24/calendar-notifier/interface/http/handler"
+	"github.com/ww24/calendar-notifier/interface/leader"
 	"github.com/ww24/calendar-notifier/usecase"
 )
 
@@ -19,10 +21,17 @@ func initialize(ctx context.Context, cnf repository.Config) (*app, error) {
 		wire.Bind(new(repository.Calendar), new(*calendar.Calendar)),
 		calendar.New,
 		wire.Bind(new(repository.ActionConfigurator), new(*action.Action)),
+		wire.Bind(new(repository.DeadLetterList), new(*action.Action)),
 		action.New,
+		history.New,
+		wire.Bind(new(repository.Leader), new(*leader.Elector)),
+		wire.Bind(new(repository.Lease), new(*leader.Elector)),
+		leader.New,
 		service.NewConfig,
 		service.NewSynchronizer,
 		usecase.NewSynchronizer,
+		usecase.NewDeadLetter,
+		usecase.NewHistory,
 		handler.New,
 		newApp,
 	)
@@ -11,7 +11,9 @@ import (
 	"github.com/ww24/calendar-notifier/domain/service"
 	"github.com/ww24/calendar-notifier/interface/action"
 	"github.com/ww24/calendar-notifier/interface/calendar"
+	"github.com/ww24/calendar-notifier/interface/history"
 	"github.com/ww24/calendar-notifier/interface/http/handler"
+	"github.com/ww24/calendar-notifier/interface/leader"
 	"github.com/ww24/calendar-notifier/usecase"
 )
 
@@ -20,13 +22,23 @@ import (
 func initialize(ctx context.Context, cnf repository.Config) (*app, error) {
 	config := service.NewConfig(cnf)
 	calendarCalendar := calendar.New(cnf)
-	actionAction, err := action.New(ctx)
+	repositoryHistory, err := history.New(cnf)
+	if err != nil {
+		return nil, err
+	}
+	elector, err := leader.New(cnf)
+	if err != nil {
+		return nil, err
+	}
+	actionAction, err := action.New(ctx, cnf, repositoryHistory, elector)
 	if err != nil {
 		return nil, err
 	}
 	synchronizer := service.NewSynchronizer(cnf, calendarCalendar, actionAction)
-	usecaseSynchronizer := usecase.NewSynchronizer(config, synchronizer)
-	httpHandler := handler.New(usecaseSynchronizer)
-	mainApp := newApp(httpHandler, usecaseSynchronizer)
+	usecaseSynchronizer := usecase.NewSynchronizer(config, synchronizer, elector, elector)
+	deadLetter := usecase.NewDeadLetter(actionAction)
+	usecaseHistory := usecase.NewHistory(repositoryHistory)
+	httpHandler := handler.New(usecaseSynchronizer, deadLetter, usecaseHistory)
+	mainApp := newApp(httpHandler, usecaseSynchronizer, actionAction, elector)
 	return mainApp, nil
 }
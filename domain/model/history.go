@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// ExecutionOutcome represents result of action execution.
+type ExecutionOutcome string
+
+const (
+	// ExecutionSucceeded is outcome that action was executed successfully.
+	ExecutionSucceeded ExecutionOutcome = "succeeded"
+	// ExecutionFailed is outcome that action failed after all attempts.
+	ExecutionFailed ExecutionOutcome = "failed"
+	// ExecutionRegistered is outcome that event was handed to external scheduler such as Cloud Tasks.
+	ExecutionRegistered ExecutionOutcome = "registered"
+)
+
+// Execution is a record of action execution.
+type Execution struct {
+	ActionName  ActionName       `json:"action_name"`
+	ActionType  ActionType       `json:"action_type"`
+	EventID     string           `json:"event_id"`
+	EventType   EventType        `json:"event_type"`
+	ScheduledAt time.Time        `json:"scheduled_at"`
+	StartedAt   time.Time        `json:"started_at"`
+	Attempts    int              `json:"attempts"`
+	Outcome     ExecutionOutcome `json:"outcome"`
+	Error       string           `json:"error,omitempty"`
+	// StatusCode is response status code of HTTP action.
+	StatusCode int `json:"status_code,omitempty"`
+	// MessageID is Pub/Sub server ID or Cloud Tasks task name.
+	MessageID string `json:"message_id,omitempty"`
+	// ExitCode is exit code of the command of exec action.
+	ExitCode *int `json:"exit_code,omitempty"`
+	// Stdout and Stderr are the tail of output of the command of exec action.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// LatencyMillis is time taken to execute action in milliseconds.
+	LatencyMillis int64 `json:"latency_ms"`
+}
+
+// HistoryQuery is condition to list execution history.
+type HistoryQuery struct {
+	// ActionName filters records by action name if it is not empty.
+	ActionName ActionName
+	// Limit is the maximum number of the latest records. Zero means no limit.
+	Limit int
+}
+
+// Match reports whether the record matches the query.
+func (q HistoryQuery) Match(e Execution) bool {
+	return q.ActionName == "" || q.ActionName == e.ActionName
+}
+
+// HistoryType represents history store type.
+type HistoryType string
+
+const (
+	// HistoryMemory keeps the latest records in memory.
+	HistoryMemory HistoryType = "memory"
+	// HistoryFile appends records to a local file as JSON lines.
+	HistoryFile HistoryType = "file"
+)
+
+// HistoryConfig is configuration of execution history store.
+type HistoryConfig struct {
+	Type HistoryType
+	// Path is file path of file store.
+	Path string
+	// Size is the number of records kept in memory store and listed from file store.
+	Size int
+}
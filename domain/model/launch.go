@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// LaunchConflictPolicy represents how launch request is handled while the other sync is in progress.
+type LaunchConflictPolicy string
+
+const (
+	// LaunchConflictJoin waits for the sync in progress and shares its result.
+	LaunchConflictJoin LaunchConflictPolicy = "join"
+	// LaunchConflictReject rejects launch request while the other sync is in progress.
+	LaunchConflictReject LaunchConflictPolicy = "reject"
+)
+
+// LaunchConfig is configuration of launch request in ondemand mode.
+type LaunchConfig struct {
+	OnConflict LaunchConflictPolicy
+	// RetryAfter is hint for rejected callers when to retry.
+	RetryAfter time.Duration
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// LeaderElectionType represents leader election backend type.
+type LeaderElectionType string
+
+const (
+	// LeaderElectionNone is type that the process is always the leader.
+	LeaderElectionNone LeaderElectionType = ""
+	// LeaderElectionFile is type which elects leader with local file lock.
+	LeaderElectionFile LeaderElectionType = "file"
+	// LeaderElectionKubernetes is type which elects leader with Kubernetes Lease.
+	LeaderElectionKubernetes LeaderElectionType = "kubernetes"
+)
+
+// LeaderElectionConfig is configuration of leader election between resident replicas.
+type LeaderElectionConfig struct {
+	Type LeaderElectionType
+	// Path is lock file path for file leader election.
+	Path string
+	// Namespace and Name identify Kubernetes Lease.
+	Namespace string
+	Name      string
+	// Identity is holder identity of the process. Hostname is used if it is empty.
+	Identity string
+	// APIServer is URL of Kubernetes API server. In-cluster config is used if it is empty.
+	APIServer string
+	// LeaseDuration is duration that followers wait before taking over leadership.
+	LeaseDuration time.Duration
+	// RetryPeriod is interval to try acquiring or renewing leadership.
+	RetryPeriod time.Duration
+}
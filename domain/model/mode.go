@@ -10,4 +10,6 @@ const (
 	ModeResident RunningMode = "resident"
 	// ModeOnDemand is on-demand running mode.
 	ModeOnDemand RunningMode = "ondemand"
+	// ModeOneShot is running mode which synchronizes once and exits.
+	ModeOneShot RunningMode = "oneshot"
 )
@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// RetryPolicy is retry configuration of action execution.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	// Zero or one means no retry.
+	MaxAttempts int
+	Backoff     Backoff
+	// RetryableStatusCodes is status codes of response which are retried.
+	// Empty means 408, 429 and 5xx.
+	RetryableStatusCodes []int
+	// Deadline is duration from ExecuteAt after which it is not retried any more.
+	// Zero means no deadline.
+	Deadline time.Duration
+}
+
+// Retryable reports whether the response status code should be retried.
+func (p RetryPolicy) Retryable(statusCode int) bool {
+	if len(p.RetryableStatusCodes) == 0 {
+		return statusCode == 408 || statusCode == 429 || statusCode >= 500
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetterType represents dead-letter sink type.
+type DeadLetterType string
+
+const (
+	// DeadLetterNone discards failed events.
+	DeadLetterNone DeadLetterType = ""
+	// DeadLetterFile appends failed events to a file as JSON lines.
+	DeadLetterFile DeadLetterType = "file"
+	// DeadLetterAction sends failed events to another action.
+	DeadLetterAction DeadLetterType = "action"
+	// DeadLetterMemory keeps failed events in memory and exposes them via admin API.
+	DeadLetterMemory DeadLetterType = "memory"
+)
+
+// DeadLetterConfig is configuration of dead-letter sink.
+type DeadLetterConfig struct {
+	Type DeadLetterType
+	// Path is file path for file sink.
+	Path string
+	// Action is action name for action sink.
+	Action ActionName
+}
+
+// DeadLetter is schedule event which failed to be executed.
+type DeadLetter struct {
+	ActionName ActionName    `json:"action_name"`
+	Event      ScheduleEvent `json:"event"`
+	Attempts   int           `json:"attempts"`
+	Error      string        `json:"error"`
+	FailedAt   time.Time     `json:"failed_at"`
+}
@@ -26,21 +26,44 @@ func (t EventType) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + strings.ToLower(t.String()) + `"`), nil
 }
 
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *EventType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	for _, et := range []EventType{None, Start, End} {
+		if strings.ToLower(et.String()) == s {
+			*t = et
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type: %s", s)
+}
+
 // Schedule is calendar schedule item.
 type Schedule struct {
 	ID          string
 	Summary     string
 	Description string
-	StartAt     time.Time
-	EndAt       time.Time
+	// Attendees is email addresses of attendees who have not declined.
+	Attendees []string
+	StartAt   time.Time
+	EndAt     time.Time
 }
 
 // Events returns schedule events from schedule.
 func (s *Schedule) Events(t time.Time) ScheduleEvents {
+	return s.EventsWithMisfire(t, MisfirePolicy{Mode: MisfireSkip})
+}
+
+// EventsWithMisfire returns schedule events from schedule.
+// Start event which has already passed is included if misfire policy allows it.
+func (s *Schedule) EventsWithMisfire(t time.Time, p MisfirePolicy) ScheduleEvents {
 	if t.After(s.EndAt) {
 		return nil
 	}
-	if t.After(s.StartAt) {
+	if t.After(s.StartAt) && !p.ShouldFire(s.StartAt, t) {
 		return []ScheduleEvent{s.EndEvent()}
 	}
 	return []ScheduleEvent{s.StartEvent(), s.EndEvent()}
@@ -52,6 +75,7 @@ func (s *Schedule) StartEvent() ScheduleEvent {
 		ScheduleID:  s.ID,
 		Summary:     s.Summary,
 		Description: s.Description,
+		Attendees:   s.Attendees,
 		EventType:   Start,
 		ExecuteAt:   s.StartAt,
 	}
@@ -63,6 +87,7 @@ func (s *Schedule) EndEvent() ScheduleEvent {
 		ScheduleID:  s.ID,
 		Summary:     s.Summary,
 		Description: s.Description,
+		Attendees:   s.Attendees,
 		EventType:   End,
 		ExecuteAt:   s.EndAt,
 	}
@@ -85,6 +110,7 @@ type ScheduleEvent struct {
 	ScheduleID  string
 	Summary     string
 	Description string
+	Attendees   []string
 	EventType   EventType
 	ExecuteAt   time.Time
 }
@@ -80,6 +80,63 @@ func TestSchedule_Events(t *testing.T) {
 	}
 }
 
+func TestSchedule_EventsWithMisfire(t *testing.T) {
+	t.Parallel()
+	s := &Schedule{
+		ID:      "id",
+		Summary: "summary",
+		StartAt: time.Unix(10, 0),
+		EndAt:   time.Unix(100, 0),
+	}
+	tests := []struct {
+		name string
+		p    MisfirePolicy
+		t    time.Time
+		want ScheduleEvents
+	}{
+		{
+			name: "skip",
+			p:    MisfirePolicy{Mode: MisfireSkip},
+			t:    time.Unix(11, 0),
+			want: ScheduleEvents{s.EndEvent()},
+		},
+		{
+			name: "fire if within",
+			p:    MisfirePolicy{Mode: MisfireFireIfWithin, Within: 30 * time.Second},
+			t:    time.Unix(40, 0),
+			want: ScheduleEvents{s.StartEvent(), s.EndEvent()},
+		},
+		{
+			name: "out of window",
+			p:    MisfirePolicy{Mode: MisfireFireIfWithin, Within: 30 * time.Second},
+			t:    time.Unix(41, 0),
+			want: ScheduleEvents{s.EndEvent()},
+		},
+		{
+			name: "always",
+			p:    MisfirePolicy{Mode: MisfireAlways},
+			t:    time.Unix(99, 0),
+			want: ScheduleEvents{s.StartEvent(), s.EndEvent()},
+		},
+		{
+			name: "ended schedule",
+			p:    MisfirePolicy{Mode: MisfireAlways},
+			t:    time.Unix(101, 0),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := s.EventsWithMisfire(tt.t, tt.p)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("\nwant: %+v\n got: %+v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestSchedules_Events(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
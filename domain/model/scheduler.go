@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// SchedulerType represents scheduler backend type.
+type SchedulerType string
+
+const (
+	// SchedulerMemory is scheduler type which keeps events in memory.
+	SchedulerMemory SchedulerType = "memory"
+	// SchedulerPersistent is scheduler type which persists events to local file.
+	SchedulerPersistent SchedulerType = "persistent"
+)
+
+// SchedulerConfig is configuration of scheduler for actions except for tasks.
+type SchedulerConfig struct {
+	Type SchedulerType
+	// Path is path to database file of persistent scheduler.
+	Path string
+	// Misfire is policy for events which came due while the process was stopped.
+	Misfire MisfirePolicy
+}
+
+// MisfireMode represents how missed executions are handled.
+type MisfireMode string
+
+const (
+	// MisfireSkip never executes missed events.
+	MisfireSkip MisfireMode = "skip"
+	// MisfireFireIfWithin executes missed events only if delay is within the window.
+	MisfireFireIfWithin MisfireMode = "fire_if_within"
+	// MisfireAlways always executes missed events.
+	MisfireAlways MisfireMode = "always"
+)
+
+// MisfirePolicy is policy for missed executions.
+// Zero value is equivalent to skip.
+type MisfirePolicy struct {
+	Mode   MisfireMode
+	Within time.Duration
+}
+
+// ShouldFire reports whether event which should have been executed at executeAt is executed at now.
+func (p MisfirePolicy) ShouldFire(executeAt, now time.Time) bool {
+	if !now.After(executeAt) {
+		return true
+	}
+	switch p.Mode {
+	case MisfireAlways:
+		return true
+	case MisfireFireIfWithin:
+		return now.Sub(executeAt) <= p.Within
+	}
+	return false
+}
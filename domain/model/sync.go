@@ -0,0 +1,67 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTooManyUnregistrations is error that sync guard refused to unregister events.
+	ErrTooManyUnregistrations = errors.New("too many unregistrations")
+	// ErrAnomalousCalendarRead is error that calendar read looks anomalous.
+	ErrAnomalousCalendarRead = errors.New("anomalous calendar read")
+)
+
+// EventError is error of an action which failed on the schedule event.
+// Actions return EventErrors joined by errors.Join, so that the other events are regarded as succeeded.
+type EventError struct {
+	Event ScheduleEvent
+	Err   error
+}
+
+func (e *EventError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Event.ID(""), e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// SyncReport is result of schedule synchronization.
+type SyncReport struct {
+	DryRun  bool           `json:"dry_run"`
+	Actions []ActionReport `json:"actions"`
+}
+
+// ActionReport is synchronization result of an action.
+type ActionReport struct {
+	Name         ActionName     `json:"name"`
+	Registered   ScheduleEvents `json:"registered"`
+	Unregistered ScheduleEvents `json:"unregistered"`
+	Unchanged    ScheduleEvents `json:"unchanged"`
+	// Expired is past events which are no longer scheduled and removed from action.
+	Expired ScheduleEvents `json:"expired"`
+	// Failed is events which failed to be registered or unregistered.
+	Failed ScheduleEvents `json:"failed"`
+	// Error is error of the action if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// SyncGuardConfig is configuration of safeguards against mass unregistration.
+type SyncGuardConfig struct {
+	// MaxUnregister is the maximum number of events unregistered by a sync. Zero means unlimited.
+	MaxUnregister int
+	// MaxUnregisterRatio is the maximum fraction of pending events unregistered by a sync.
+	// Zero means unlimited.
+	MaxUnregisterRatio float64
+	// AllowEmptyCalendar accepts empty calendar read which unregisters pending events.
+	AllowEmptyCalendar bool
+}
+
+// ConcurrencyConfig is configuration of concurrent requests on sync.
+type ConcurrencyConfig struct {
+	// Actions is the number of actions reconciled concurrently.
+	Actions int
+	// Tasks is the number of Cloud Tasks requests in flight shared by all of tasks actions.
+	Tasks int
+}
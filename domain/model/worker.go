@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// WorkerConfig is configuration of resident worker.
+type WorkerConfig struct {
+	// MaxConsecutiveFailures is the number of consecutive sync failures to give up.
+	// Negative value means the worker never gives up.
+	MaxConsecutiveFailures int
+	Backoff                Backoff
+}
+
+// Backoff is configuration of exponential backoff.
+type Backoff struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+}
+
+// HealthStatus represents health status.
+type HealthStatus string
+
+const (
+	// HealthOK is status that the last sync succeeded.
+	HealthOK HealthStatus = "ok"
+	// HealthDegraded is status that sync is failing and being retried.
+	HealthDegraded HealthStatus = "degraded"
+	// HealthFailed is status that worker gave up retrying sync.
+	HealthFailed HealthStatus = "failed"
+)
+
+// Health is health status of synchronizer.
+type Health struct {
+	Status              HealthStatus `json:"status"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastSuccessAt       *time.Time   `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time   `json:"last_failure_at,omitempty"`
+}
@@ -11,8 +11,17 @@ import (
 // Config is the interface to get configurations.
 type Config interface {
 	ActionNames(model.ScheduleEvent) ([]model.ActionName, bool)
+	MisfirePolicy(model.ScheduleEvent) model.MisfirePolicy
 	ActionConfigMap() map[model.ActionName]model.ActionConfig
 	RunningMode() model.RunningMode
 	SyncInterval() time.Duration
+	DryRunEnabled() bool
+	WorkerConfig() model.WorkerConfig
+	SchedulerConfig() model.SchedulerConfig
+	HistoryConfig() model.HistoryConfig
+	LeaderElectionConfig() model.LeaderElectionConfig
+	LaunchConfig() model.LaunchConfig
+	SyncGuardConfig() model.SyncGuardConfig
+	ConcurrencyConfig() model.ConcurrencyConfig
 	Calendar() string
 }
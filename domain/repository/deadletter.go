@@ -0,0 +1,12 @@
+//go:generate mockgen -source=$GOFILE -destination=../../mock/mock_$GOPACKAGE/mock_$GOFILE -package=mock_repository
+
+package repository
+
+import (
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// DeadLetterList is the interface to list dead letters kept in memory.
+type DeadLetterList interface {
+	DeadLetters() []model.DeadLetter
+}
@@ -0,0 +1,13 @@
+//go:generate mockgen -source=$GOFILE -destination=../../mock/mock_$GOPACKAGE/mock_$GOFILE -package=mock_repository
+
+package repository
+
+import (
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// History is the interface to record and query execution history.
+type History interface {
+	Record(model.Execution) error
+	List(model.HistoryQuery) ([]model.Execution, error)
+}
@@ -0,0 +1,19 @@
+//go:generate mockgen -source=$GOFILE -destination=../../mock/mock_$GOPACKAGE/mock_$GOFILE -package=mock_repository
+
+package repository
+
+import "context"
+
+// Leader is the interface to check leadership between resident replicas.
+type Leader interface {
+	IsLeader() bool
+	// Acquired notifies that the process has become the leader.
+	Acquired() <-chan struct{}
+}
+
+// Lease is the interface to hold exclusive lease across instances during a sync in ondemand mode.
+type Lease interface {
+	// TryAcquire tries to acquire the lease and reports whether it is held.
+	TryAcquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
@@ -11,6 +11,9 @@ import (
 type Config interface {
 	RunningMode() model.RunningMode
 	SyncInterval() time.Duration
+	DryRunEnabled() bool
+	WorkerConfig() model.WorkerConfig
+	LaunchConfig() model.LaunchConfig
 }
 
 // NewConfig returns config.
@@ -31,3 +34,15 @@ func (c *config) RunningMode() model.RunningMode {
 func (c *config) SyncInterval() time.Duration {
 	return c.cnf.SyncInterval()
 }
+
+func (c *config) DryRunEnabled() bool {
+	return c.cnf.DryRunEnabled()
+}
+
+func (c *config) WorkerConfig() model.WorkerConfig {
+	return c.cnf.WorkerConfig()
+}
+
+func (c *config) LaunchConfig() model.LaunchConfig {
+	return c.cnf.LaunchConfig()
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+type forceKey struct{}
+
+// WithForce returns context which overrides sync guard, e.g. when the calendar is cleared on purpose.
+func WithForce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceKey{}, true)
+}
+
+func forced(ctx context.Context) bool {
+	force, _ := ctx.Value(forceKey{}).(bool)
+	return force
+}
+
+// guard checks the plan against sync guard and returns error if unregistration should be refused.
+// Ratio and empty calendar checks apply only if more than one event would be unregistered,
+// so that a single canceled schedule never trips them.
+func guard(g model.SyncGuardConfig, am map[model.ActionName]*action, schedules model.Schedules, now time.Time) error {
+	var pending, unregister int
+	for _, act := range am {
+		for _, e := range act.events {
+			if e.ExecuteAt.After(now) {
+				pending++
+			}
+		}
+		unregister += len(act.unregister)
+	}
+	if g.MaxUnregister > 0 && unregister > g.MaxUnregister {
+		return fmt.Errorf("%w: %d events would be unregistered (max: %d)",
+			model.ErrTooManyUnregistrations, unregister, g.MaxUnregister)
+	}
+	if unregister <= 1 {
+		return nil
+	}
+	if g.MaxUnregisterRatio > 0 && float64(unregister) > float64(pending)*g.MaxUnregisterRatio {
+		return fmt.Errorf("%w: %d of %d pending events would be unregistered (max ratio: %g)",
+			model.ErrTooManyUnregistrations, unregister, pending, g.MaxUnregisterRatio)
+	}
+	if !g.AllowEmptyCalendar && len(schedules) == 0 {
+		return fmt.Errorf("%w: calendar is empty but %d pending events would be unregistered",
+			model.ErrAnomalousCalendarRead, unregister)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	pending := func(n int) model.ScheduleEvents {
+		events := make(model.ScheduleEvents, 0, n)
+		for i := 0; i < n; i++ {
+			events = append(events, model.ScheduleEvent{
+				ScheduleID: string(rune('a' + i)),
+				ExecuteAt:  now.Add(time.Duration(i+1) * time.Hour),
+			})
+		}
+		return events
+	}
+	schedules := model.Schedules{{ID: "sid"}}
+	tests := []struct {
+		name       string
+		guard      model.SyncGuardConfig
+		events     model.ScheduleEvents
+		unregister int
+		schedules  model.Schedules
+		want       error
+	}{
+		{
+			name:       "within max unregister",
+			guard:      model.SyncGuardConfig{MaxUnregister: 2},
+			events:     pending(4),
+			unregister: 2,
+			schedules:  schedules,
+			want:       nil,
+		},
+		{
+			name:       "exceeds max unregister",
+			guard:      model.SyncGuardConfig{MaxUnregister: 2},
+			events:     pending(4),
+			unregister: 3,
+			schedules:  schedules,
+			want:       model.ErrTooManyUnregistrations,
+		},
+		{
+			name:       "exceeds max unregister ratio",
+			guard:      model.SyncGuardConfig{MaxUnregisterRatio: 0.5},
+			events:     pending(4),
+			unregister: 3,
+			schedules:  schedules,
+			want:       model.ErrTooManyUnregistrations,
+		},
+		{
+			name:       "single unregistration never trips ratio",
+			guard:      model.SyncGuardConfig{MaxUnregisterRatio: 0.5},
+			events:     pending(1),
+			unregister: 1,
+			schedules:  model.Schedules{},
+			want:       nil,
+		},
+		{
+			name:       "empty calendar",
+			guard:      model.SyncGuardConfig{},
+			events:     pending(2),
+			unregister: 2,
+			schedules:  model.Schedules{},
+			want:       model.ErrAnomalousCalendarRead,
+		},
+		{
+			name:       "empty calendar is allowed",
+			guard:      model.SyncGuardConfig{AllowEmptyCalendar: true},
+			events:     pending(2),
+			unregister: 2,
+			schedules:  model.Schedules{},
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			am := map[model.ActionName]*action{
+				"test": {events: tt.events, unregister: tt.events[:tt.unregister]},
+			}
+			if err := guard(tt.guard, am, tt.schedules, now); !errors.Is(err, tt.want) {
+				t.Fatalf("want %+v but got %+v", tt.want, err)
+			}
+		})
+	}
+}
@@ -2,10 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
 	"github.com/ww24/calendar-notifier/domain/model"
 	"github.com/ww24/calendar-notifier/domain/repository"
 )
@@ -14,7 +19,7 @@ const calendarScanRange = 24 * time.Hour
 
 // Synchronizer is schedule synchronizer service.
 type Synchronizer interface {
-	Sync(context.Context) error
+	Sync(context.Context) (*model.SyncReport, error)
 }
 
 // NewSynchronizer returns synchronizer.
@@ -36,86 +41,300 @@ type synchronizer struct {
 }
 
 type action struct {
-	action repository.Action
-	events model.ScheduleEvents
+	action     repository.Action
+	events     model.ScheduleEvents
+	register   model.ScheduleEvents
+	unregister model.ScheduleEvents
+	expired    model.ScheduleEvents
+	unchanged  model.ScheduleEvents
+	failed     model.ScheduleEvents
+	errs       []error
+}
+
+func (a *action) fail(err error, events ...model.ScheduleEvent) {
+	a.errs = append(a.errs, err)
+	a.failed = append(a.failed, events...)
+}
+
+// failedEvents returns events which failed by err of the action.
+// All of events are regarded as failed unless err consists of model.EventError.
+func failedEvents(err error, events model.ScheduleEvents) model.ScheduleEvents {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	failed := make(model.ScheduleEvents, 0, len(errs))
+	for _, err := range errs {
+		var ee *model.EventError
+		if !errors.As(err, &ee) {
+			return events
+		}
+		failed = append(failed, ee.Event)
+	}
+	return failed
 }
 
-func (s *synchronizer) Sync(ctx context.Context) error {
+// actionErrors aggregates errors of actions.
+type actionErrors []error
+
+func (e actionErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e actionErrors) Unwrap() []error {
+	return e
+}
+
+// Sync synchronizes calendar schedules with actions.
+// Each action is reconciled independently and concurrently, so that an action error does not stop
+// the other actions. It returns the report with errors of all failed actions.
+// If dry run is enabled, it returns the plan without registering and unregistering events.
+// If the plan trips sync guard, events are registered but no event is unregistered,
+// and it returns error unless the guard is overridden by WithForce.
+func (s *synchronizer) Sync(ctx context.Context) (*model.SyncReport, error) {
 	now := time.Now()
 	schedules, err := s.cal.List(ctx, now, now.Add(calendarScanRange))
 	if err != nil {
-		return fmt.Errorf("calendar.List: %w", err)
+		return nil, fmt.Errorf("calendar.List: %w", err)
 	}
 	log.Println("calendar.List:", len(schedules))
 
 	acm := s.cnf.ActionConfigMap()
 	am := make(map[model.ActionName]*action, len(acm))
+	concurrency := s.cnf.ConcurrencyConfig().Actions
 
-	if err := s.initialize(ctx, am, acm); err != nil {
-		return err
-	}
+	s.initialize(ctx, am, acm, concurrency)
 	log.Println("s.initialize:", len(am))
 
-	if err := s.register(ctx, am, schedules.Events(now)); err != nil {
-		return err
+	s.plan(am, s.events(schedules, now), now)
+
+	guardErr := guard(s.cnf.SyncGuardConfig(), am, schedules, now)
+	if guardErr != nil && forced(ctx) {
+		log.Println("sync guard is overridden:", guardErr)
+		guardErr = nil
 	}
 
-	if err := s.unregister(ctx, am); err != nil {
-		return err
+	if s.cnf.DryRunEnabled() {
+		report := s.report(am, true)
+		if guardErr != nil {
+			log.Println("[dry run] unregistration would be refused:", guardErr)
+		}
+		for _, ar := range report.Actions {
+			for _, e := range ar.Registered {
+				log.Printf("[dry run] action.Register[%s]: %s %s at %s\n", ar.Name, e.Summary, e.EventType, e.ExecuteAt.Format(time.RFC3339))
+			}
+			for _, e := range ar.Unregistered {
+				log.Printf("[dry run] action.Unregister[%s]: %s at %s\n", ar.Name, e.ScheduleID, e.ExecuteAt.Format(time.RFC3339))
+			}
+			for _, e := range ar.Expired {
+				log.Printf("[dry run] action.Unregister[%s]: %s at %s (expired)\n", ar.Name, e.ScheduleID, e.ExecuteAt.Format(time.RFC3339))
+			}
+		}
+		return report, s.collectErrors(am, nil)
+	}
+
+	// registered events are kept, but pending events are never wiped silently
+	if guardErr != nil {
+		for _, act := range am {
+			act.unchanged = append(act.unchanged, act.unregister...)
+			act.unchanged = append(act.unchanged, act.expired...)
+			act.unregister = make(model.ScheduleEvents, 0)
+			act.expired = make(model.ScheduleEvents, 0)
+		}
 	}
+	parallel(am, concurrency, func(actionName model.ActionName, act *action) {
+		if act.action == nil {
+			return
+		}
+		s.register(ctx, actionName, act)
+		s.unregister(ctx, actionName, act)
+	})
 
-	return nil
+	report := s.report(am, false)
+	logReport(report)
+	return report, s.collectErrors(am, guardErr)
 }
 
-func (s *synchronizer) initialize(ctx context.Context, am map[model.ActionName]*action, acm map[model.ActionName]model.ActionConfig) error {
-	for an, ac := range acm {
-		a, err := s.ac.Configure(ac)
+// initialize configures actions and lists their registered events concurrently.
+// Actions which failed to initialize are excluded from reconciliation.
+func (s *synchronizer) initialize(ctx context.Context, am map[model.ActionName]*action, acm map[model.ActionName]model.ActionConfig, concurrency int) {
+	for an := range acm {
+		am[an] = &action{}
+	}
+	parallel(am, concurrency, func(an model.ActionName, act *action) {
+		a, err := s.ac.Configure(acm[an])
 		if err != nil {
-			return fmt.Errorf("actionConfig.Configure: %w", err)
+			act.fail(fmt.Errorf("actionConfig.Configure[%s]: %w", an, err))
+			return
 		}
 		events, err := a.List(ctx)
 		if err != nil {
-			return fmt.Errorf("action.List: %w", err)
-		}
-		am[an] = &action{
-			action: a,
-			events: events,
+			act.fail(fmt.Errorf("action.List[%s]: %w", an, err))
+			return
 		}
+		act.action = a
+		act.events = events
+	})
+}
+
+// parallel calls fn for each action with bounded concurrency, and waits for all of them.
+// Each action is touched by only one goroutine.
+func parallel(am map[model.ActionName]*action, concurrency int, fn func(model.ActionName, *action)) {
+	var g errgroup.Group
+	if concurrency > 0 {
+		g.SetLimit(concurrency)
+	}
+	for actionName, act := range am {
+		actionName, act := actionName, act
+		g.Go(func() error {
+			fn(actionName, act)
+			return nil
+		})
 	}
-	return nil
+	_ = g.Wait()
 }
 
-func (s *synchronizer) register(ctx context.Context, am map[model.ActionName]*action, events model.ScheduleEvents) error {
-	for actionName, events := range s.route(events) {
-		act, ok := am[actionName]
-		if !ok {
+// events returns schedule events to be scheduled at now.
+// Start events which have already passed are included according to misfire policy of event handler.
+func (s *synchronizer) events(schedules model.Schedules, now time.Time) model.ScheduleEvents {
+	events := make(model.ScheduleEvents, 0, len(schedules)*2)
+	for _, sc := range schedules {
+		p := s.cnf.MisfirePolicy(sc.StartEvent())
+		events = append(events, sc.EventsWithMisfire(now, p)...)
+	}
+	return events
+}
+
+// plan computes events to register and unregister for each action.
+// Past events which are no longer scheduled are expired instead of unregistered.
+// Events of actions which failed to initialize are regarded as failed.
+func (s *synchronizer) plan(am map[model.ActionName]*action, events model.ScheduleEvents, now time.Time) {
+	routedEvents := s.route(events)
+	for actionName := range routedEvents {
+		if _, ok := am[actionName]; !ok {
 			log.Println("action not defined:", actionName)
-			continue
 		}
-		if err := act.action.Register(ctx, events.Sub(act.events)...); err != nil {
-			return fmt.Errorf("action.Register: %w", err)
+	}
+	for actionName, act := range am {
+		events := routedEvents[actionName]
+		act.register = make(model.ScheduleEvents, 0)
+		act.unregister = make(model.ScheduleEvents, 0)
+		act.expired = make(model.ScheduleEvents, 0)
+		act.unchanged = make(model.ScheduleEvents, 0)
+		if act.failed == nil {
+			act.failed = make(model.ScheduleEvents, 0)
+		}
+		if act.action == nil {
+			act.failed = append(act.failed, events...)
+			continue
 		}
-		if len(events.Sub(act.events)) > 0 {
-			log.Printf("action.Register[%s]: %d\n", actionName, len(events.Sub(act.events)))
+		act.register = events.Sub(act.events)
+		for _, e := range act.events.Sub(events) {
+			if e.ExecuteAt.After(now) {
+				act.unregister = append(act.unregister, e)
+			} else {
+				act.expired = append(act.expired, e)
+			}
 		}
-		act.events = act.events.Sub(events)
+		act.unchanged = act.events.Sub(act.unregister).Sub(act.expired)
 	}
-	return nil
 }
 
-func (s *synchronizer) unregister(ctx context.Context, am map[model.ActionName]*action) error {
-	for actionName, act := range am {
-		if len(act.events) == 0 {
-			continue
+func (s *synchronizer) report(am map[model.ActionName]*action, dryRun bool) *model.SyncReport {
+	names := make([]string, 0, len(am))
+	for actionName := range am {
+		names = append(names, string(actionName))
+	}
+	sort.Strings(names)
+
+	report := &model.SyncReport{
+		DryRun:  dryRun,
+		Actions: make([]model.ActionReport, 0, len(am)),
+	}
+	for _, name := range names {
+		act := am[model.ActionName(name)]
+		ar := model.ActionReport{
+			Name:         model.ActionName(name),
+			Registered:   act.register,
+			Unregistered: act.unregister,
+			Unchanged:    act.unchanged,
+			Expired:      act.expired,
+			Failed:       act.failed,
 		}
-		if err := act.action.Unregister(ctx, act.events...); err != nil {
-			return fmt.Errorf("action.Unregister: %w", err)
+		if len(act.errs) > 0 {
+			ar.Error = actionErrors(act.errs).Error()
 		}
-		if len(act.events) > 0 {
-			log.Printf("action.Unegister[%s]: %d\n", actionName, len(act.events))
+		report.Actions = append(report.Actions, ar)
+	}
+	return report
+}
+
+// collectErrors returns errors of all actions in order of action name, followed by sync guard error.
+func (s *synchronizer) collectErrors(am map[model.ActionName]*action, guardErr error) error {
+	names := make([]string, 0, len(am))
+	for actionName := range am {
+		names = append(names, string(actionName))
+	}
+	sort.Strings(names)
+
+	errs := make(actionErrors, 0)
+	for _, name := range names {
+		errs = append(errs, am[model.ActionName(name)].errs...)
+	}
+	if guardErr != nil {
+		errs = append(errs, guardErr)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// register registers events to the action. Events are regarded as failed if the action fails on them.
+func (s *synchronizer) register(ctx context.Context, actionName model.ActionName, act *action) {
+	if len(act.register) == 0 {
+		return
+	}
+	if err := act.action.Register(ctx, act.register...); err != nil {
+		failed := failedEvents(err, act.register)
+		act.fail(fmt.Errorf("action.Register[%s]: %w", actionName, err), failed...)
+		act.register = act.register.Sub(failed)
+		return
+	}
+	log.Printf("action.Register[%s]: %d\n", actionName, len(act.register))
+}
+
+// unregister unregisters events from the action. Events are regarded as failed if the action fails on them.
+func (s *synchronizer) unregister(ctx context.Context, actionName model.ActionName, act *action) {
+	events := make(model.ScheduleEvents, 0, len(act.unregister)+len(act.expired))
+	events = append(events, act.unregister...)
+	events = append(events, act.expired...)
+	if len(events) == 0 {
+		return
+	}
+	if err := act.action.Unregister(ctx, events...); err != nil {
+		failed := failedEvents(err, events)
+		act.fail(fmt.Errorf("action.Unregister[%s]: %w", actionName, err), failed...)
+		act.unregister = act.unregister.Sub(failed)
+		act.expired = act.expired.Sub(failed)
+		return
+	}
+	log.Printf("action.Unegister[%s]: %d (expired: %d)\n", actionName, len(act.unregister), len(act.expired))
+}
+
+// logReport logs summary of the report for each action.
+func logReport(report *model.SyncReport) {
+	for _, ar := range report.Actions {
+		log.Printf("sync report[%s]: registered=%d, unregistered=%d, unchanged=%d, expired=%d, failed=%d\n",
+			ar.Name, len(ar.Registered), len(ar.Unregistered), len(ar.Unchanged), len(ar.Expired), len(ar.Failed))
+		if ar.Error != "" {
+			log.Printf("sync report[%s]: error: %s\n", ar.Name, ar.Error)
 		}
 	}
-	return nil
 }
 
 func (s *synchronizer) route(events []model.ScheduleEvent) map[model.ActionName]model.ScheduleEvents {
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tenntenn/testtime"
 
+	"github.com/ww24/calendar-notifier/domain/model"
 	"github.com/ww24/calendar-notifier/mock/mock_repository"
 )
 
@@ -18,8 +20,35 @@ func TestSynchronizer_Sync(t *testing.T) {
 	t.Parallel()
 
 	errCalendar := errors.New("calendar error")
+	errAction := errors.New("action error")
 	ts := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
 	ctx := context.Background()
+	schedule := model.Schedule{
+		ID:      "sid",
+		Summary: "test",
+		StartAt: ts.Add(time.Hour),
+		EndAt:   ts.Add(2 * time.Hour),
+	}
+	staleEvent := model.ScheduleEvent{
+		ScheduleID: "stale",
+		ExecuteAt:  ts.Add(3 * time.Hour),
+	}
+	anotherStaleEvent := model.ScheduleEvent{
+		ScheduleID: "stale2",
+		ExecuteAt:  ts.Add(4 * time.Hour),
+	}
+	startedSchedule := model.Schedule{
+		ID:      "started",
+		Summary: "test",
+		StartAt: ts.Add(-10 * time.Minute),
+		EndAt:   ts.Add(time.Hour),
+	}
+	firedEvent := model.ScheduleEvent{
+		ScheduleID: "fired",
+		ExecuteAt:  ts.Add(-time.Hour),
+	}
+	skip := model.MisfirePolicy{Mode: model.MisfireSkip}
+	actionConfig := model.ActionConfig{Name: "test", Type: model.ActionHTTP}
 	tests := []struct {
 		name     string
 		injector func(
@@ -28,7 +57,8 @@ func TestSynchronizer_Sync(t *testing.T) {
 			*mock_repository.MockActionConfigurator,
 			*mock_repository.MockAction,
 		)
-		want error
+		want       error
+		wantReport *model.SyncReport
 	}{
 		{
 			name: "Sync with calendar error",
@@ -42,7 +72,214 @@ func TestSynchronizer_Sync(t *testing.T) {
 			},
 			want: errCalendar,
 		},
-		// TODO: add more tests
+		{
+			name: "Sync registers and unregisters events",
+			injector: func(
+				cnf *mock_repository.MockConfig,
+				cal *mock_repository.MockCalendar,
+				ac *mock_repository.MockActionConfigurator,
+				action *mock_repository.MockAction,
+			) {
+				cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{schedule}, nil)
+				cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+					"test": actionConfig,
+				})
+				cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+				cnf.EXPECT().MisfirePolicy(schedule.StartEvent()).Return(skip)
+				cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"test"}, true).Times(2)
+				cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+				cnf.EXPECT().DryRunEnabled().Return(false)
+				ac.EXPECT().Configure(actionConfig).Return(action, nil)
+				action.EXPECT().List(ctx).Return(model.ScheduleEvents{schedule.StartEvent(), staleEvent}, nil)
+				action.EXPECT().Register(ctx, schedule.EndEvent()).Return(nil)
+				action.EXPECT().Unregister(ctx, staleEvent).Return(nil)
+			},
+			want: nil,
+			wantReport: &model.SyncReport{
+				Actions: []model.ActionReport{{
+					Name:         "test",
+					Registered:   model.ScheduleEvents{schedule.EndEvent()},
+					Unregistered: model.ScheduleEvents{staleEvent},
+					Unchanged:    model.ScheduleEvents{schedule.StartEvent()},
+					Expired:      model.ScheduleEvents{},
+					Failed:       model.ScheduleEvents{},
+				}},
+			},
+		},
+		{
+			name: "Sync catches up missed start event and expires past events",
+			injector: func(
+				cnf *mock_repository.MockConfig,
+				cal *mock_repository.MockCalendar,
+				ac *mock_repository.MockActionConfigurator,
+				action *mock_repository.MockAction,
+			) {
+				cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{startedSchedule}, nil)
+				cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+					"test": actionConfig,
+				})
+				cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+				cnf.EXPECT().MisfirePolicy(startedSchedule.StartEvent()).Return(model.MisfirePolicy{
+					Mode:   model.MisfireFireIfWithin,
+					Within: 15 * time.Minute,
+				})
+				cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"test"}, true).Times(2)
+				cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+				cnf.EXPECT().DryRunEnabled().Return(false)
+				ac.EXPECT().Configure(actionConfig).Return(action, nil)
+				action.EXPECT().List(ctx).Return(model.ScheduleEvents{firedEvent}, nil)
+				action.EXPECT().Register(ctx, startedSchedule.StartEvent(), startedSchedule.EndEvent()).Return(nil)
+				action.EXPECT().Unregister(ctx, firedEvent).Return(nil)
+			},
+			want: nil,
+			wantReport: &model.SyncReport{
+				Actions: []model.ActionReport{{
+					Name:         "test",
+					Registered:   model.ScheduleEvents{startedSchedule.StartEvent(), startedSchedule.EndEvent()},
+					Unregistered: model.ScheduleEvents{},
+					Unchanged:    model.ScheduleEvents{},
+					Expired:      model.ScheduleEvents{firedEvent},
+					Failed:       model.ScheduleEvents{},
+				}},
+			},
+		},
+		{
+			name: "Sync with dry run",
+			injector: func(
+				cnf *mock_repository.MockConfig,
+				cal *mock_repository.MockCalendar,
+				ac *mock_repository.MockActionConfigurator,
+				action *mock_repository.MockAction,
+			) {
+				cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{schedule}, nil)
+				cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+					"test": actionConfig,
+				})
+				cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+				cnf.EXPECT().MisfirePolicy(schedule.StartEvent()).Return(skip)
+				cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"test"}, true).Times(2)
+				cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+				cnf.EXPECT().DryRunEnabled().Return(true)
+				ac.EXPECT().Configure(actionConfig).Return(action, nil)
+				action.EXPECT().List(ctx).Return(model.ScheduleEvents{staleEvent}, nil)
+			},
+			want: nil,
+			wantReport: &model.SyncReport{
+				DryRun: true,
+				Actions: []model.ActionReport{{
+					Name:         "test",
+					Registered:   model.ScheduleEvents{schedule.StartEvent(), schedule.EndEvent()},
+					Unregistered: model.ScheduleEvents{staleEvent},
+					Unchanged:    model.ScheduleEvents{},
+					Expired:      model.ScheduleEvents{},
+					Failed:       model.ScheduleEvents{},
+				}},
+			},
+		},
+		{
+			name: "Sync registers events but refuses mass unregistration",
+			injector: func(
+				cnf *mock_repository.MockConfig,
+				cal *mock_repository.MockCalendar,
+				ac *mock_repository.MockActionConfigurator,
+				action *mock_repository.MockAction,
+			) {
+				cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{schedule}, nil)
+				cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+					"test": actionConfig,
+				})
+				cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+				cnf.EXPECT().MisfirePolicy(schedule.StartEvent()).Return(skip)
+				cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"test"}, true).Times(2)
+				cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{MaxUnregister: 1})
+				cnf.EXPECT().DryRunEnabled().Return(false)
+				ac.EXPECT().Configure(actionConfig).Return(action, nil)
+				action.EXPECT().List(ctx).Return(model.ScheduleEvents{staleEvent, anotherStaleEvent}, nil)
+				action.EXPECT().Register(ctx, schedule.StartEvent(), schedule.EndEvent()).Return(nil)
+			},
+			want: model.ErrTooManyUnregistrations,
+			wantReport: &model.SyncReport{
+				Actions: []model.ActionReport{{
+					Name:         "test",
+					Registered:   model.ScheduleEvents{schedule.StartEvent(), schedule.EndEvent()},
+					Unregistered: model.ScheduleEvents{},
+					Unchanged:    model.ScheduleEvents{staleEvent, anotherStaleEvent},
+					Expired:      model.ScheduleEvents{},
+					Failed:       model.ScheduleEvents{},
+				}},
+			},
+		},
+		{
+			name: "Sync reports failed events of action",
+			injector: func(
+				cnf *mock_repository.MockConfig,
+				cal *mock_repository.MockCalendar,
+				ac *mock_repository.MockActionConfigurator,
+				action *mock_repository.MockAction,
+			) {
+				cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{schedule}, nil)
+				cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+					"test": actionConfig,
+				})
+				cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+				cnf.EXPECT().MisfirePolicy(schedule.StartEvent()).Return(skip)
+				cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"test"}, true).Times(2)
+				cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+				cnf.EXPECT().DryRunEnabled().Return(false)
+				ac.EXPECT().Configure(actionConfig).Return(action, nil)
+				action.EXPECT().List(ctx).Return(model.ScheduleEvents{staleEvent}, nil)
+				action.EXPECT().Register(ctx, schedule.StartEvent(), schedule.EndEvent()).Return(errAction)
+				action.EXPECT().Unregister(ctx, staleEvent).Return(nil)
+			},
+			want: errAction,
+			wantReport: &model.SyncReport{
+				Actions: []model.ActionReport{{
+					Name:         "test",
+					Registered:   model.ScheduleEvents{},
+					Unregistered: model.ScheduleEvents{staleEvent},
+					Unchanged:    model.ScheduleEvents{},
+					Expired:      model.ScheduleEvents{},
+					Failed:       model.ScheduleEvents{schedule.StartEvent(), schedule.EndEvent()},
+					Error:        "action.Register[test]: action error",
+				}},
+			},
+		},
+		{
+			name: "Sync reports only failed events of action",
+			injector: func(
+				cnf *mock_repository.MockConfig,
+				cal *mock_repository.MockCalendar,
+				ac *mock_repository.MockActionConfigurator,
+				action *mock_repository.MockAction,
+			) {
+				cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{schedule}, nil)
+				cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+					"test": actionConfig,
+				})
+				cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+				cnf.EXPECT().MisfirePolicy(schedule.StartEvent()).Return(skip)
+				cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"test"}, true).Times(2)
+				cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+				cnf.EXPECT().DryRunEnabled().Return(false)
+				ac.EXPECT().Configure(actionConfig).Return(action, nil)
+				action.EXPECT().List(ctx).Return(model.ScheduleEvents{staleEvent}, nil)
+				action.EXPECT().Register(ctx, schedule.StartEvent(), schedule.EndEvent()).
+					Return(errors.Join(&model.EventError{Event: schedule.EndEvent(), Err: errAction}))
+				action.EXPECT().Unregister(ctx, staleEvent).Return(nil)
+			},
+			want: errAction,
+			wantReport: &model.SyncReport{
+				Actions: []model.ActionReport{{
+					Name:         "test",
+					Registered:   model.ScheduleEvents{schedule.StartEvent()},
+					Unregistered: model.ScheduleEvents{staleEvent},
+					Unchanged:    model.ScheduleEvents{},
+					Expired:      model.ScheduleEvents{},
+					Failed:       model.ScheduleEvents{schedule.EndEvent()},
+					Error:        "action.Register[test]: sid:7200: action error",
+				}},
+			},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -58,8 +295,127 @@ func TestSynchronizer_Sync(t *testing.T) {
 			tt.injector(cnf, cal, ac, action)
 
 			s := NewSynchronizer(cnf, cal, ac)
-			err := s.Sync(ctx)
+			report, err := s.Sync(ctx)
 			assert.ErrorIs(t, err, tt.want)
+			assert.Equal(t, tt.wantReport, report)
 		})
 	}
 }
+
+func TestSynchronizer_Sync_force(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.True(t, testtime.SetTime(t, ts))
+	ctx := WithForce(context.Background())
+	events := model.ScheduleEvents{
+		{ScheduleID: "sid1", ExecuteAt: ts.Add(time.Hour)},
+		{ScheduleID: "sid2", ExecuteAt: ts.Add(2 * time.Hour)},
+	}
+	actionConfig := model.ActionConfig{Name: "test", Type: model.ActionHTTP}
+
+	ctrl := gomock.NewController(t)
+	cnf := mock_repository.NewMockConfig(ctrl)
+	cal := mock_repository.NewMockCalendar(ctrl)
+	ac := mock_repository.NewMockActionConfigurator(ctrl)
+	action := mock_repository.NewMockAction(ctrl)
+	cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{}, nil)
+	cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{"test": actionConfig})
+	cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+	cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+	cnf.EXPECT().DryRunEnabled().Return(false)
+	ac.EXPECT().Configure(actionConfig).Return(action, nil)
+	action.EXPECT().List(ctx).Return(events, nil)
+	action.EXPECT().Unregister(ctx, events[0], events[1]).Return(nil)
+
+	s := NewSynchronizer(cnf, cal, ac)
+	_, err := s.Sync(ctx)
+	assert.NoError(t, err)
+}
+
+func TestSynchronizer_Sync_partialFailure(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.True(t, testtime.SetTime(t, ts))
+	ctx := context.Background()
+	errConfigure := errors.New("topic not found")
+	schedule := model.Schedule{
+		ID:      "sid",
+		Summary: "test",
+		StartAt: ts.Add(time.Hour),
+		EndAt:   ts.Add(2 * time.Hour),
+	}
+	broken := model.ActionConfig{Name: "broken", Type: model.ActionPubSub}
+	healthy := model.ActionConfig{Name: "healthy", Type: model.ActionHTTP}
+
+	ctrl := gomock.NewController(t)
+	cnf := mock_repository.NewMockConfig(ctrl)
+	cal := mock_repository.NewMockCalendar(ctrl)
+	ac := mock_repository.NewMockActionConfigurator(ctrl)
+	action := mock_repository.NewMockAction(ctrl)
+	cal.EXPECT().List(ctx, ts, ts.Add(24*time.Hour)).Return(model.Schedules{schedule}, nil)
+	cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{
+		"broken":  broken,
+		"healthy": healthy,
+	})
+	cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{Actions: 2})
+	cnf.EXPECT().MisfirePolicy(schedule.StartEvent()).Return(model.MisfirePolicy{Mode: model.MisfireSkip})
+	cnf.EXPECT().ActionNames(gomock.Any()).Return([]model.ActionName{"broken", "healthy"}, true).Times(2)
+	cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{})
+	cnf.EXPECT().DryRunEnabled().Return(false)
+	ac.EXPECT().Configure(broken).Return(nil, errConfigure)
+	ac.EXPECT().Configure(healthy).Return(action, nil)
+	action.EXPECT().List(ctx).Return(model.ScheduleEvents{}, nil)
+	action.EXPECT().Register(ctx, schedule.StartEvent(), schedule.EndEvent()).Return(nil)
+
+	s := NewSynchronizer(cnf, cal, ac)
+	report, err := s.Sync(ctx)
+	assert.ErrorIs(t, err, errConfigure)
+	assert.Equal(t, &model.SyncReport{
+		Actions: []model.ActionReport{
+			{
+				Name:         "broken",
+				Registered:   model.ScheduleEvents{},
+				Unregistered: model.ScheduleEvents{},
+				Unchanged:    model.ScheduleEvents{},
+				Expired:      model.ScheduleEvents{},
+				Failed:       model.ScheduleEvents{schedule.StartEvent(), schedule.EndEvent()},
+				Error:        "actionConfig.Configure[broken]: topic not found",
+			},
+			{
+				Name:         "healthy",
+				Registered:   model.ScheduleEvents{schedule.StartEvent(), schedule.EndEvent()},
+				Unregistered: model.ScheduleEvents{},
+				Unchanged:    model.ScheduleEvents{},
+				Expired:      model.ScheduleEvents{},
+				Failed:       model.ScheduleEvents{},
+			},
+		},
+	}, report)
+}
+
+func TestParallel(t *testing.T) {
+	t.Parallel()
+	am := make(map[model.ActionName]*action)
+	for _, name := range []model.ActionName{"a", "b", "c", "d", "e"} {
+		am[name] = &action{}
+	}
+	var inFlight, maxInFlight, calls int32
+	parallel(am, 2, func(_ model.ActionName, act *action) {
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if cur <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, cur) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		act.register = model.ScheduleEvents{}
+		atomic.AddInt32(&calls, 1)
+	})
+	assert.Equal(t, int32(len(am)), atomic.LoadInt32(&calls))
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+	for name, act := range am {
+		assert.NotNil(t, act.register, name)
+	}
+}
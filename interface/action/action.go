@@ -3,27 +3,116 @@ package action
 import (
 	"context"
 	"fmt"
+	"log"
+	"reflect"
 	"sync"
+	"time"
 
 	"github.com/ww24/calendar-notifier/domain/model"
 	"github.com/ww24/calendar-notifier/domain/repository"
+	"github.com/ww24/calendar-notifier/interface/action/amqp"
+	"github.com/ww24/calendar-notifier/interface/action/email"
+	"github.com/ww24/calendar-notifier/interface/action/exec"
+	"github.com/ww24/calendar-notifier/interface/action/grpc"
 	"github.com/ww24/calendar-notifier/interface/action/http"
+	"github.com/ww24/calendar-notifier/interface/action/kafka"
+	"github.com/ww24/calendar-notifier/interface/action/mqtt"
+	"github.com/ww24/calendar-notifier/interface/action/nats"
+	"github.com/ww24/calendar-notifier/interface/action/plugin"
 	"github.com/ww24/calendar-notifier/interface/action/pubsub"
+	"github.com/ww24/calendar-notifier/interface/action/registry"
+	"github.com/ww24/calendar-notifier/interface/action/slack"
 	"github.com/ww24/calendar-notifier/interface/action/tasks"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
 )
 
 // Action represents notify actions.
 type Action struct {
-	parent    context.Context
-	tasksCli  *tasks.Client
-	pubsubCli *pubsub.Client
-	httpCli   *http.Client
+	parent      context.Context
+	sc          model.SchedulerConfig
+	configs     map[model.ActionName]model.ActionConfig
+	concurrency model.ConcurrencyConfig
+	history     repository.History
+	leader      repository.Leader
+	store       *scheduler.Store
+	tasksCli    *tasks.Client
+	pubsubCli   *pubsub.Client
+	httpCli     *http.Client
+	slackCli    *slack.Client
+	emailCli    *email.Client
+	execCli     *exec.Client
+	mqttCli     *mqtt.Client
+	natsCli     *nats.Client
+	amqpCli     *amqp.Client
+	kafkaCli    *kafka.Client
+	grpcCli     *grpc.Client
+	pluginCli   *plugin.Client
+	registered  map[model.ActionName]registeredAction
+	deadLetters deadLetterList
+	fileMu      sync.Mutex
 	sync.Mutex
 }
 
+// registeredAction is action of registered action type which is reused for the same action config.
+type registeredAction struct {
+	cnf    model.ActionConfig
+	action repository.Action
+}
+
 // New returns action.
-func New(ctx context.Context) (*Action, error) {
-	return &Action{parent: ctx}, nil
+// Scheduled events are executed only while the process is the leader.
+func New(ctx context.Context, cnf repository.Config, history repository.History, leader repository.Leader) (*Action, error) {
+	return &Action{
+		parent:      ctx,
+		sc:          cnf.SchedulerConfig(),
+		configs:     cnf.ActionConfigMap(),
+		concurrency: cnf.ConcurrencyConfig(),
+		history:     history,
+		leader:      leader,
+		registered:  make(map[model.ActionName]registeredAction),
+	}, nil
+}
+
+// newScheduler returns scheduler for actions except for tasks.
+// Handler is retried according to retry policy of each action, and executions are recorded to history.
+func (a *Action) newScheduler(namespace string, handler scheduler.Handler) (scheduler.Scheduler, error) {
+	handler = a.withRetry(handler)
+	opts := []scheduler.Option{}
+	if a.leader != nil {
+		opts = append(opts, scheduler.WithGate(a.leader.IsLeader))
+	}
+	switch a.sc.Type {
+	case model.SchedulerPersistent:
+		if a.store == nil {
+			store, err := scheduler.OpenStore(a.sc.Path)
+			if err != nil {
+				return nil, err
+			}
+			a.store = store
+		}
+		return scheduler.NewPersistent(a.parent, a.store, namespace, handler, a.sc.Misfire, a.known, opts...)
+	default:
+		return scheduler.NewInMemory(a.parent, handler, opts...), nil
+	}
+}
+
+// known reports whether action is defined in config.
+func (a *Action) known(name model.ActionName) bool {
+	_, ok := a.config(name)
+	return ok
+}
+
+// config returns action config defined in config.
+// Clients look up connection settings and credentials by it on execution.
+// Dead letters are executed with config of the dead-letter action, and they are not sent to dead letter again.
+func (a *Action) config(name model.ActionName) (model.ActionConfig, bool) {
+	if target, ok := deadLetterTarget(name); ok {
+		ac, ok := a.configs[target]
+		ac.DeadLetter = model.DeadLetterConfig{}
+		return ac, ok
+	}
+	ac, ok := a.configs[name]
+	return ac, ok
 }
 
 // Configure returns action from action config.
@@ -38,14 +127,105 @@ func (a *Action) Configure(ac model.ActionConfig) (repository.Action, error) {
 		return a.configurePubSubAction(ac)
 	case model.ActionTasks:
 		return a.configureTasksAction(ac)
+	case model.ActionSlack:
+		return a.configureSlackAction(ac)
+	case model.ActionEmail:
+		return a.configureEmailAction(ac)
+	case model.ActionExec:
+		return a.configureExecAction(ac)
+	case model.ActionMQTT:
+		return a.configureMQTTAction(ac)
+	case model.ActionNATS:
+		return a.configureNATSAction(ac)
+	case model.ActionAMQP:
+		return a.configureAMQPAction(ac)
+	case model.ActionKafka:
+		return a.configureKafkaAction(ac)
+	case model.ActionGRPC:
+		return a.configureGRPCAction(ac)
+	case model.ActionPlugin:
+		return a.configurePluginAction(ac)
+	}
+	if f, ok := registry.Lookup(ac.Type); ok {
+		return a.configureRegisteredAction(f, ac)
 	}
 
 	return nil, fmt.Errorf("Not implemented: %s", ac.Type)
 }
 
+// Shutdown shuts down action clients gracefully.
+// It waits for running handlers and handlers scheduled within grace window,
+// and returns schedule events which are abandoned.
+func (a *Action) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	// running handlers may configure action to send dead letters,
+	// so that the lock is not held while waiting for them.
+	a.Lock()
+	httpCli, pubsubCli, slackCli, emailCli, execCli := a.httpCli, a.pubsubCli, a.slackCli, a.emailCli, a.execCli
+	mqttCli, natsCli, amqpCli, kafkaCli := a.mqttCli, a.natsCli, a.amqpCli, a.kafkaCli
+	grpcCli, pluginCli, tasksCli, store := a.grpcCli, a.pluginCli, a.tasksCli, a.store
+	registered := make([]registeredAction, 0, len(a.registered))
+	for _, ra := range a.registered {
+		registered = append(registered, ra)
+	}
+	a.Unlock()
+
+	abandoned := make([]model.ActionEvent, 0)
+	if httpCli != nil {
+		abandoned = append(abandoned, httpCli.Shutdown(ctx, grace)...)
+	}
+	if pubsubCli != nil {
+		abandoned = append(abandoned, pubsubCli.Shutdown(ctx, grace)...)
+	}
+	if slackCli != nil {
+		abandoned = append(abandoned, slackCli.Shutdown(ctx, grace)...)
+	}
+	if emailCli != nil {
+		abandoned = append(abandoned, emailCli.Shutdown(ctx, grace)...)
+	}
+	if execCli != nil {
+		abandoned = append(abandoned, execCli.Shutdown(ctx, grace)...)
+	}
+	if mqttCli != nil {
+		abandoned = append(abandoned, mqttCli.Shutdown(ctx, grace)...)
+	}
+	if natsCli != nil {
+		abandoned = append(abandoned, natsCli.Shutdown(ctx, grace)...)
+	}
+	if amqpCli != nil {
+		abandoned = append(abandoned, amqpCli.Shutdown(ctx, grace)...)
+	}
+	if kafkaCli != nil {
+		abandoned = append(abandoned, kafkaCli.Shutdown(ctx, grace)...)
+	}
+	if grpcCli != nil {
+		abandoned = append(abandoned, grpcCli.Shutdown(ctx, grace)...)
+	}
+	for _, ra := range registered {
+		if s, ok := ra.action.(registry.Shutdowner); ok {
+			abandoned = append(abandoned, s.Shutdown(ctx, grace)...)
+		}
+	}
+	if tasksCli != nil {
+		if err := tasksCli.Close(); err != nil {
+			log.Println("[tasks action] close error:", err)
+		}
+	}
+	if pluginCli != nil {
+		if err := pluginCli.Close(); err != nil {
+			log.Println("[plugin action] close error:", err)
+		}
+	}
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.Println("[scheduler] close error:", err)
+		}
+	}
+	return abandoned
+}
+
 func (a *Action) configureHTTPAction(ac model.ActionConfig) (repository.Action, error) {
 	if a.httpCli == nil {
-		cli, err := http.NewClient(a.parent)
+		cli, err := http.NewClient(a.parent, a.newScheduler, a.config)
 		if err != nil {
 			return nil, err
 		}
@@ -56,7 +236,7 @@ func (a *Action) configureHTTPAction(ac model.ActionConfig) (repository.Action,
 
 func (a *Action) configurePubSubAction(ac model.ActionConfig) (repository.Action, error) {
 	if a.pubsubCli == nil {
-		cli, err := pubsub.NewClient(a.parent)
+		cli, err := pubsub.NewClient(a.parent, a.newScheduler)
 		if err != nil {
 			return nil, err
 		}
@@ -67,7 +247,7 @@ func (a *Action) configurePubSubAction(ac model.ActionConfig) (repository.Action
 
 func (a *Action) configureTasksAction(ac model.ActionConfig) (repository.Action, error) {
 	if a.tasksCli == nil {
-		cli, err := tasks.NewClient(a.parent)
+		cli, err := tasks.NewClient(a.parent, a.history, a.concurrency.Tasks)
 		if err != nil {
 			return nil, err
 		}
@@ -75,3 +255,120 @@ func (a *Action) configureTasksAction(ac model.ActionConfig) (repository.Action,
 	}
 	return tasks.New(a.tasksCli, ac), nil
 }
+
+func (a *Action) configureSlackAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.slackCli == nil {
+		cli, err := slack.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.slackCli = cli
+	}
+	return slack.New(a.slackCli, ac)
+}
+
+func (a *Action) configureEmailAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.emailCli == nil {
+		cli, err := email.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.emailCli = cli
+	}
+	return email.New(a.emailCli, ac)
+}
+
+func (a *Action) configureExecAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.execCli == nil {
+		cli, err := exec.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.execCli = cli
+	}
+	return exec.New(a.execCli, ac), nil
+}
+
+func (a *Action) configureMQTTAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.mqttCli == nil {
+		cli, err := mqtt.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.mqttCli = cli
+	}
+	return mqtt.New(a.mqttCli, ac)
+}
+
+func (a *Action) configureNATSAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.natsCli == nil {
+		cli, err := nats.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.natsCli = cli
+	}
+	return nats.New(a.natsCli, ac)
+}
+
+func (a *Action) configureAMQPAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.amqpCli == nil {
+		cli, err := amqp.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.amqpCli = cli
+	}
+	return amqp.New(a.amqpCli, ac)
+}
+
+func (a *Action) configureKafkaAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.kafkaCli == nil {
+		cli, err := kafka.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.kafkaCli = cli
+	}
+	return kafka.New(a.kafkaCli, ac)
+}
+
+func (a *Action) configureGRPCAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.grpcCli == nil {
+		cli, err := grpc.NewClient(a.parent, a.newScheduler, a.config)
+		if err != nil {
+			return nil, err
+		}
+		a.grpcCli = cli
+	}
+	return grpc.New(a.grpcCli, ac)
+}
+
+func (a *Action) configurePluginAction(ac model.ActionConfig) (repository.Action, error) {
+	if a.pluginCli == nil {
+		a.pluginCli = plugin.NewClient()
+	}
+	return plugin.New(a.pluginCli, ac)
+}
+
+// configureRegisteredAction returns action of registered action type.
+// Action is reused for the same action config, and the previous one is shut down if the config has been changed.
+func (a *Action) configureRegisteredAction(f registry.Factory, ac model.ActionConfig) (repository.Action, error) {
+	if ra, ok := a.registered[ac.Name]; ok {
+		if reflect.DeepEqual(ra.cnf, ac) {
+			return ra.action, nil
+		}
+		if s, ok := ra.action.(registry.Shutdowner); ok {
+			if abandoned := s.Shutdown(a.parent, 0); len(abandoned) > 0 {
+				log.Printf("[%s action] %s: abandoned %d events\n", ac.Type, ac.Name, len(abandoned))
+			}
+		}
+		delete(a.registered, ac.Name)
+	}
+	action, err := f.New(a.parent, ac)
+	if err != nil {
+		return nil, err
+	}
+	a.registered[ac.Name] = registeredAction{cnf: ac, action: action}
+	return action, nil
+}
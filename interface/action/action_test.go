@@ -0,0 +1,76 @@
+package action
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+	"github.com/ww24/calendar-notifier/interface/action/registry"
+)
+
+// countAction is action of registered action type which abandons an event on shutdown.
+type countAction struct {
+	repository.Action
+	name model.ActionName
+}
+
+func (a *countAction) Shutdown(context.Context, time.Duration) []model.ActionEvent {
+	return []model.ActionEvent{{ActionName: a.name}}
+}
+
+type countFactory struct {
+	created int32
+}
+
+func (f *countFactory) NewParams() interface{}     { return &struct{}{} }
+func (f *countFactory) Validate(interface{}) error { return nil }
+func (f *countFactory) New(_ context.Context, ac model.ActionConfig) (repository.Action, error) {
+	atomic.AddInt32(&f.created, 1)
+	return &countAction{name: ac.Name}, nil
+}
+
+var (
+	testFactory      = &countFactory{}
+	registerTypeOnce sync.Once
+)
+
+func TestAction_Configure_registered(t *testing.T) {
+	t.Parallel()
+	registerTypeOnce.Do(func() {
+		if err := registry.Register("count", testFactory); err != nil {
+			t.Fatal(err)
+		}
+	})
+	a := &Action{
+		parent:     context.Background(),
+		registered: make(map[model.ActionName]registeredAction),
+	}
+	ac := model.ActionConfig{Name: "counter", Type: "count"}
+	created := atomic.LoadInt32(&testFactory.created)
+	// action is configured on every sync
+	var first repository.Action
+	for i := 0; i < 3; i++ {
+		got, err := a.Configure(ac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = got
+		}
+		if got != first {
+			t.Fatal("action should be reused for the same action config")
+		}
+	}
+	if got := atomic.LoadInt32(&testFactory.created) - created; got != 1 {
+		t.Fatalf("want 1 action created but got %d", got)
+	}
+
+	abandoned := a.Shutdown(context.Background(), 0)
+	if len(abandoned) != 1 || abandoned[0].ActionName != "counter" {
+		t.Fatalf("abandoned events of registered action should be returned: %+v", abandoned)
+	}
+}
@@ -0,0 +1,310 @@
+package amqp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"text/template"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const (
+	timeout   = 15 * time.Second
+	namespace = "amqp"
+	appID     = "calendar-notifier"
+	// maxRoutingKeyLen is limit of shortstr in AMQP 0-9-1.
+	maxRoutingKeyLen = 255
+	// returnBuffer is buffer size of returned messages, which includes messages returned after publish gave up.
+	returnBuffer = 8
+)
+
+var (
+	// ErrNacked is error that the broker did not confirm the message.
+	ErrNacked = errors.New("message is nacked by the broker")
+	// ErrReturned is error that the message could not be routed to any queue.
+	ErrReturned = errors.New("message is returned by the broker")
+)
+
+// AMQP implements repository.Action for AMQP publish.
+type AMQP struct {
+	cli         *Client
+	name        model.ActionName
+	exchange    string
+	contentType string
+	headers     map[string]string
+	transient   bool
+	payload     map[string]interface{}
+	templates   *Templates
+}
+
+// Client represents AMQP client which reuses connections to brokers.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+	conns     map[string]*conn
+	mu        sync.Mutex
+}
+
+// conn is connection and channel in confirm mode.
+// Messages are published one by one on the channel, and returned messages are read after each publisher confirm.
+type conn struct {
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	returns chan amqp.Return
+	mu      sync.Mutex
+}
+
+func (c *conn) close() {
+	if err := c.conn.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+		log.Println("[amqp action] close error:", err)
+	}
+}
+
+// message is AMQP message which is stored in scheduler.
+type message struct {
+	Exchange    string            `json:"exchange"`
+	RoutingKey  string            `json:"routing_key"`
+	ContentType string            `json:"content_type"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Persistent  bool              `json:"persistent"`
+	MessageID   string            `json:"message_id"`
+	Body        []byte            `json:"body"`
+}
+
+// Templates is routing key and message templates of AMQP action.
+type Templates struct {
+	routingKey *template.Template
+	message    *template.Template
+}
+
+// ParseTemplates parses routing key and message templates of AMQP action.
+func ParseTemplates(aa model.AMQPAction) (*Templates, error) {
+	t := &Templates{}
+	var err error
+	if t.routingKey, err = render.Parse("routing_key", aa.RoutingKey); err != nil {
+		return nil, err
+	}
+	if t.message, err = render.Parse("message", aa.Message); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewClient returns AMQP client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{
+		configs: configs,
+		conns:   make(map[string]*conn),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+// channel returns connection and channel to the broker.
+// It reconnects to the broker if the connection or the channel has been closed.
+func (c *Client) channel(url string) (*conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cn, ok := c.conns[url]; ok {
+		if !cn.ch.IsClosed() {
+			return cn, nil
+		}
+		log.Println("[amqp action] reconnecting")
+		cn.close()
+		delete(c.conns, url)
+	}
+
+	ac, err := amqp.DialConfig(url, amqp.Config{
+		Dial:       amqp.DefaultDial(timeout),
+		Properties: amqp.Table{"connection_name": appID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ch, err := ac.Channel()
+	if err != nil {
+		ac.Close()
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ac.Close()
+		return nil, err
+	}
+	// the broker returns mandatory message before it confirms the message
+	cn := &conn{conn: ac, ch: ch, returns: ch.NotifyReturn(make(chan amqp.Return, returnBuffer))}
+	log.Println("[amqp action] connected")
+	c.conns[url] = cn
+	return cn, nil
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	cn, err := c.channel(ac.AMQP.URL)
+	if err != nil {
+		return err
+	}
+
+	headers := make(amqp.Table, len(m.Headers))
+	for k, v := range m.Headers {
+		headers[k] = v
+	}
+	msg := amqp.Publishing{
+		Headers:      headers,
+		ContentType:  m.ContentType,
+		DeliveryMode: amqp.Transient,
+		MessageId:    m.MessageID,
+		Timestamp:    time.Now(),
+		AppId:        appID,
+		Body:         m.Body,
+	}
+	if m.Persistent {
+		msg.DeliveryMode = amqp.Persistent
+	}
+	if err := cn.publish(ctx, m.Exchange, m.RoutingKey, msg); err != nil {
+		return err
+	}
+	log.Printf("[amqp action] published, exchange: %s, routing_key: %s\n", m.Exchange, m.RoutingKey)
+	scheduler.SetResult(ctx, scheduler.Result{MessageID: m.MessageID})
+	return nil
+}
+
+// publish publishes mandatory message and waits for the publisher confirm.
+// Message which is not routed to any queue is returned by the broker, and it is failure.
+func (cn *conn) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	dc, err := cn.ch.PublishWithDeferredConfirm(exchange, routingKey, true, false, msg)
+	if err != nil {
+		return err
+	}
+
+	// confirmation is released as nack when the channel is closed
+	acked := make(chan bool, 1)
+	go func() { acked <- dc.Wait() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ack := <-acked:
+		if !ack {
+			return ErrNacked
+		}
+	case <-timer.C:
+		return errors.New("publisher confirm timed out")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for {
+		select {
+		case r, ok := <-cn.returns:
+			if !ok {
+				return nil
+			}
+			if r.MessageId == msg.MessageId {
+				return permanent.New(fmt.Errorf("%w: %d %s", ErrReturned, r.ReplyCode, r.ReplyText))
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// Shutdown shuts down AMQP action scheduler and returns abandoned events.
+// Connections are closed after scheduled events are published.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	abandoned := c.scheduler.Shutdown(ctx, grace)
+	c.mu.Lock()
+	for url, cn := range c.conns {
+		cn.close()
+		delete(c.conns, url)
+	}
+	c.mu.Unlock()
+	return abandoned
+}
+
+// New returns an action for AMQP publish.
+func New(cli *Client, ac model.ActionConfig) (*AMQP, error) {
+	t, err := ParseTemplates(ac.AMQP)
+	if err != nil {
+		return nil, err
+	}
+	return &AMQP{
+		cli:         cli,
+		name:        ac.Name,
+		exchange:    ac.AMQP.Exchange,
+		contentType: ac.AMQP.ContentType,
+		headers:     ac.AMQP.Headers,
+		transient:   ac.AMQP.Transient,
+		payload:     ac.Payload,
+		templates:   t,
+	}, nil
+}
+
+// List lists schedule events from AMQP action scheduler.
+func (a *AMQP) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders messages of schedule events and registers them to AMQP action scheduler.
+func (a *AMQP) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	return render.Register(a.cli.scheduler, a.name, events, func(event model.ScheduleEvent) (interface{}, error) {
+		return a.newMessage(event)
+	})
+}
+
+func (a *AMQP) newMessage(event model.ScheduleEvent) (*message, error) {
+	data := render.NewData(a.name, event)
+	routingKey, err := render.Execute(a.templates.routingKey, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(routingKey) > maxRoutingKeyLen {
+		return nil, fmt.Errorf("routing key is longer than %d bytes: %q", maxRoutingKeyLen, routingKey)
+	}
+	body, err := render.Body(a.templates.message, data, a.payload, event)
+	if err != nil {
+		return nil, err
+	}
+	contentType := a.contentType
+	if contentType == "" && a.templates.message != nil {
+		contentType = "text/plain"
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return &message{
+		Exchange:    a.exchange,
+		RoutingKey:  routingKey,
+		ContentType: contentType,
+		Headers:     a.headers,
+		Persistent:  !a.transient,
+		MessageID:   string(a.name) + ":" + event.ID(""),
+		Body:        body,
+	}, nil
+}
+
+// Unregister unregisters schedule events from AMQP action scheduler.
+func (a *AMQP) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
@@ -0,0 +1,376 @@
+package amqp
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+func TestAMQP_newMessage(t *testing.T) {
+	t.Parallel()
+	event := model.ScheduleEvent{ScheduleID: "sid", Summary: "light", EventType: model.Start}
+	tests := []struct {
+		name            string
+		action          model.AMQPAction
+		payload         map[string]interface{}
+		wantRoutingKey  string
+		wantContentType string
+		wantBody        string
+		wantPersistent  bool
+		wantErr         bool
+	}{
+		{
+			name:            "payload is published as JSON",
+			action:          model.AMQPAction{Exchange: "home", RoutingKey: "{{.Summary}}.{{.EventType}}"},
+			payload:         map[string]interface{}{"state": "ON"},
+			wantRoutingKey:  "light.Start",
+			wantContentType: "application/json",
+			wantBody:        `{"state":"ON"}`,
+			wantPersistent:  true,
+		},
+		{
+			name: "message template is published as text",
+			action: model.AMQPAction{
+				RoutingKey: "light",
+				Message:    "{{.Summary}} {{.EventType}}",
+				Transient:  true,
+			},
+			wantRoutingKey:  "light",
+			wantContentType: "text/plain",
+			wantBody:        "light Start",
+		},
+		{
+			name: "content type is configurable",
+			action: model.AMQPAction{
+				RoutingKey:  "light",
+				Message:     `{"summary":"{{.Summary}}"}`,
+				ContentType: "application/json",
+			},
+			wantRoutingKey:  "light",
+			wantContentType: "application/json",
+			wantBody:        `{"summary":"light"}`,
+			wantPersistent:  true,
+		},
+		{
+			name:    "routing key should not be too long",
+			action:  model.AMQPAction{RoutingKey: strings.Repeat("a", 256)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := New(&Client{}, model.ActionConfig{Name: "light", AMQP: tt.action, Payload: tt.payload})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := a.newMessage(event)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err should not be nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.RoutingKey != tt.wantRoutingKey {
+				t.Errorf("want routing key %s but got %s", tt.wantRoutingKey, m.RoutingKey)
+			}
+			if m.ContentType != tt.wantContentType {
+				t.Errorf("want content type %s but got %s", tt.wantContentType, m.ContentType)
+			}
+			if string(m.Body) != tt.wantBody {
+				t.Errorf("want body %s but got %s", tt.wantBody, m.Body)
+			}
+			if m.Persistent != tt.wantPersistent {
+				t.Errorf("want persistent %v but got %v", tt.wantPersistent, m.Persistent)
+			}
+			if m.MessageID != "light:"+event.ID("") {
+				t.Errorf("unexpected message id: %s", m.MessageID)
+			}
+		})
+	}
+}
+
+// frame types and frame end of AMQP 0-9-1.
+const (
+	frameMethod = 1
+	frameHeader = 2
+	frameBody   = 3
+	frameEnd    = 0xce
+)
+
+// testBroker is a minimal AMQP 0-9-1 broker which confirms published messages.
+// Messages are nacked if the routing key is "nack", and returned if it is "unroutable".
+type testBroker struct {
+	ln        net.Listener
+	mu        sync.Mutex
+	conns     []net.Conn
+	published []string
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{ln: ln}
+	t.Cleanup(func() {
+		ln.Close()
+		b.disconnect()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) url() string {
+	return "amqp://guest:guest@" + b.ln.Addr().String() + "/"
+}
+
+// disconnect closes connections of clients.
+func (b *testBroker) disconnect() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+}
+
+func (b *testBroker) connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.conns)
+}
+
+type frame struct {
+	typ     byte
+	channel uint16
+	payload []byte
+}
+
+func readFrame(r io.Reader) (*frame, error) {
+	var h [7]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(h[3:])+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return &frame{typ: h[0], channel: binary.BigEndian.Uint16(h[1:]), payload: payload[:len(payload)-1]}, nil
+}
+
+func writeFrame(w io.Writer, typ byte, channel uint16, payload []byte) error {
+	b := make([]byte, 7, 8+len(payload))
+	b[0] = typ
+	binary.BigEndian.PutUint16(b[1:], channel)
+	binary.BigEndian.PutUint32(b[3:], uint32(len(payload)))
+	b = append(append(b, payload...), frameEnd)
+	_, err := w.Write(b)
+	return err
+}
+
+// method encodes method payload, and args are encoded arguments.
+func method(class, id uint16, args ...[]byte) []byte {
+	b := binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, class), id)
+	for _, arg := range args {
+		b = append(b, arg...)
+	}
+	return b
+}
+
+func shortstr(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func longstr(s string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+func short(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+func long(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func longlong(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return
+	}
+	start := method(10, 10, []byte{0, 9}, long(0), longstr("PLAIN"), longstr("en_US"))
+	if err := writeFrame(conn, frameMethod, 0, start); err != nil {
+		return
+	}
+	var (
+		tag     uint64
+		publish []byte
+		content []byte
+	)
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		var replies [][]byte
+		switch f.typ {
+		case frameMethod:
+			switch m := binary.BigEndian.Uint32(f.payload); m {
+			case 10<<16 | 11: // connection.start-ok
+				replies = append(replies, method(10, 30, short(0), long(131072), short(0)))
+			case 10<<16 | 40: // connection.open
+				replies = append(replies, method(10, 41, shortstr("")))
+			case 10<<16 | 50: // connection.close
+				_ = writeFrame(conn, frameMethod, 0, method(10, 51))
+				return
+			case 20<<16 | 10: // channel.open
+				replies = append(replies, method(20, 11, longstr("")))
+			case 20<<16 | 40: // channel.close
+				replies = append(replies, method(20, 41))
+			case 85<<16 | 10: // confirm.select
+				replies = append(replies, method(85, 11))
+			case 60<<16 | 40: // basic.publish
+				publish = f.payload
+				continue
+			}
+		case frameHeader:
+			content = f.payload
+			if binary.BigEndian.Uint64(content[4:]) > 0 {
+				continue
+			}
+			fallthrough
+		case frameBody:
+			// reserved short, exchange and routing key follow class and method
+			args := publish[6:]
+			exchange := string(args[1 : 1+args[0]])
+			args = args[1+args[0]:]
+			routingKey := string(args[1 : 1+args[0]])
+			b.mu.Lock()
+			b.published = append(b.published, routingKey)
+			b.mu.Unlock()
+			tag++
+			switch routingKey {
+			case "nack":
+				replies = append(replies, method(60, 120, longlong(tag), []byte{0}))
+			case "unroutable":
+				ret := method(60, 50, short(312), shortstr("NO_ROUTE"), shortstr(exchange), shortstr(routingKey))
+				if err := writeFrame(conn, frameMethod, f.channel, ret); err != nil {
+					return
+				}
+				if err := writeFrame(conn, frameHeader, f.channel, content); err != nil {
+					return
+				}
+				if err := writeFrame(conn, frameBody, f.channel, f.payload); err != nil {
+					return
+				}
+				replies = append(replies, method(60, 80, longlong(tag), []byte{0}))
+			default:
+				replies = append(replies, method(60, 80, longlong(tag), []byte{0}))
+			}
+		}
+		for _, reply := range replies {
+			if err := writeFrame(conn, frameMethod, f.channel, reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	b := newTestBroker(t)
+	ac := model.ActionConfig{Name: "light", AMQP: model.AMQPAction{URL: b.url(), Exchange: "home", RoutingKey: "{{.Summary}}"}}
+	c := &Client{
+		configs: func(name model.ActionName) (model.ActionConfig, bool) { return ac, name == ac.Name },
+		conns:   make(map[string]*conn),
+	}
+	t.Cleanup(func() {
+		for _, cn := range c.conns {
+			cn.close()
+		}
+	})
+	a, err := New(c, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execute := func(summary string) error {
+		event := model.ScheduleEvent{ScheduleID: summary, Summary: summary, EventType: model.Start}
+		m, err := a.newMessage(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return c.execute(ctx, &scheduler.Task{ActionName: a.name, Event: event, Payload: d})
+	}
+
+	if err := execute("light"); err != nil {
+		t.Fatalf("confirmed message should succeed: %v", err)
+	}
+	if err := execute("nack"); !errors.Is(err, ErrNacked) {
+		t.Fatalf("want %v but got %v", ErrNacked, err)
+	}
+	if err := execute("unroutable"); !errors.Is(err, ErrReturned) {
+		t.Fatalf("want %v but got %v", ErrReturned, err)
+	}
+	if err := execute("light"); err != nil {
+		t.Fatalf("message after returned message should succeed: %v", err)
+	}
+	if got := b.connections(); got != 1 {
+		t.Fatalf("connection should be reused: %d", got)
+	}
+
+	// reconnect after the connection is closed by the broker
+	b.disconnect()
+	cn := c.conns[ac.AMQP.URL]
+	deadline := time.Now().Add(5 * time.Second)
+	for !cn.ch.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("channel should be closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := execute("light"); err != nil {
+		t.Fatalf("message should be published after reconnection: %v", err)
+	}
+	if got := b.connections(); got != 2 {
+		t.Fatalf("client should reconnect: %d", got)
+	}
+}
@@ -0,0 +1,120 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const (
+	// deadLetterListSize is the number of dead letters kept in memory.
+	deadLetterListSize = 100
+	// deadLetterDelimiter separates dead-letter action and failed action in action name of dead letters.
+	deadLetterDelimiter = "#dead-letter:"
+)
+
+// deadLetterList is bounded list of dead letters which drops the oldest one.
+type deadLetterList struct {
+	items []model.DeadLetter
+	mu    sync.RWMutex
+}
+
+func (l *deadLetterList) add(dl model.DeadLetter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.items) >= deadLetterListSize {
+		l.items = l.items[1:]
+	}
+	l.items = append(l.items, dl)
+}
+
+func (l *deadLetterList) list() []model.DeadLetter {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := make([]model.DeadLetter, len(l.items))
+	copy(res, l.items)
+	return res
+}
+
+// DeadLetters returns dead letters kept in memory sinks.
+func (a *Action) DeadLetters() []model.DeadLetter {
+	return a.deadLetters.list()
+}
+
+func (a *Action) sendDeadLetter(ctx context.Context, dc model.DeadLetterConfig, dl model.DeadLetter) error {
+	log.Printf("[dead letter] action=%s, schedule_id=%s, type=%s, attempts=%d: %s\n",
+		dl.ActionName, dl.Event.ScheduleID, dl.Event.EventType, dl.Attempts, dl.Error)
+	switch dc.Type {
+	case model.DeadLetterFile:
+		return a.appendDeadLetter(dc.Path, dl)
+	case model.DeadLetterAction:
+		ac, ok := a.configs[dc.Action]
+		if !ok {
+			return fmt.Errorf("action (%s) is not defined", dc.Action)
+		}
+		// dead letter is registered under its own action name, so that it does not conflict with
+		// events of the other action and sync does not unregister it.
+		ac.Name = deadLetterName(dc.Action, dl.ActionName)
+		act, err := a.Configure(ac)
+		if err != nil {
+			return err
+		}
+		// execute failed event by the other action immediately
+		event := dl.Event
+		event.ExecuteAt = time.Now()
+		return act.Register(ctx, event)
+	case model.DeadLetterMemory:
+		a.deadLetters.add(dl)
+	}
+	return nil
+}
+
+// deadLetterName returns action name of dead letters sent from failed action to dead-letter action.
+func deadLetterName(target, failed model.ActionName) model.ActionName {
+	return target + deadLetterDelimiter + failed
+}
+
+// deadLetterTarget returns dead-letter action of action name of dead letters.
+func deadLetterTarget(name model.ActionName) (model.ActionName, bool) {
+	target, _, ok := strings.Cut(string(name), deadLetterDelimiter)
+	return model.ActionName(target), ok
+}
+
+// unregisterDeadLetter unregisters executed dead letter from dead-letter action.
+func (a *Action) unregisterDeadLetter(ctx context.Context, ac model.ActionConfig, task *scheduler.Task) {
+	ac.Name = task.ActionName
+	act, err := a.Configure(ac)
+	if err == nil {
+		err = act.Unregister(ctx, task.Event)
+	}
+	if err != nil {
+		log.Println("[dead letter] unregister error:", err)
+	}
+}
+
+// appendDeadLetter appends dead letter to file as JSON lines.
+func (a *Action) appendDeadLetter(path string, dl model.DeadLetter) error {
+	d, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	a.fileMu.Lock()
+	defer a.fileMu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(d, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
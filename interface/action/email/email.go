@@ -0,0 +1,363 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const (
+	timeout        = 30 * time.Second
+	namespace      = "email"
+	defaultSubject = "{{.Summary}} ({{.EventType}})"
+	defaultText    = "{{.Summary}}\n\n{{.Description}}\n"
+)
+
+// Email implements repository.Action for email.
+type Email struct {
+	cli       *Client
+	name      model.ActionName
+	from      *mail.Address
+	to        []string
+	attendees bool
+	templates *Templates
+}
+
+// Client represents SMTP client.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+}
+
+// message is rendered email which is stored in scheduler.
+type message struct {
+	From      string   `json:"from"`
+	To        []string `json:"to"`
+	MessageID string   `json:"message_id"`
+	Data      []byte   `json:"data"`
+}
+
+// Templates is message templates of email action.
+type Templates struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// ParseTemplates parses subject, text and HTML templates of email action.
+func ParseTemplates(ea model.EmailAction) (*Templates, error) {
+	subject, text := ea.Subject, ea.Text
+	if subject == "" {
+		subject = defaultSubject
+	}
+	if text == "" {
+		text = defaultText
+	}
+	t := &Templates{}
+	var err error
+	if t.subject, err = render.Parse("subject", subject); err != nil {
+		return nil, err
+	}
+	if t.text, err = render.Parse("text", text); err != nil {
+		return nil, err
+	}
+	if ea.HTML != "" {
+		if t.html, err = htmltemplate.New("html").Funcs(htmltemplate.FuncMap(render.Funcs)).Parse(ea.HTML); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// NewClient returns SMTP client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{configs: configs}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	if len(m.To) == 0 {
+		log.Println("[email action] no recipients, schedule_id:", task.Event.ScheduleID)
+		return nil
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	// Date header is set on sending.
+	data := append([]byte("Date: "+time.Now().Format(time.RFC1123Z)+"\r\n"), m.Data...)
+	if err := c.send(ctx, ac.Email.SMTP, m, data); err != nil {
+		return err
+	}
+	log.Println("[email action] sent, message_id:", m.MessageID)
+	scheduler.SetResult(ctx, scheduler.Result{MessageID: m.MessageID})
+	return nil
+}
+
+func (c *Client) send(ctx context.Context, cnf model.SMTPConfig, m *message, data []byte) error {
+	addr := net.JoinHostPort(cnf.Host, strconv.Itoa(cnf.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if cnf.TLS == model.SMTPTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: cnf.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	cli, err := smtp.NewClient(conn, cnf.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer cli.Close()
+	if cnf.TLS == model.SMTPTLSStartTLS {
+		if ok, _ := cli.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := cli.StartTLS(&tls.Config{ServerName: cnf.Host}); err != nil {
+			return err
+		}
+	}
+	if cnf.Username != "" {
+		if err := cli.Auth(smtp.PlainAuth("", cnf.Username, cnf.Password, cnf.Host)); err != nil {
+			return err
+		}
+	}
+	if err := cli.Mail(m.From); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err := cli.Rcpt(to); err != nil {
+			return fmt.Errorf("rcpt %s: %w", to, err)
+		}
+	}
+	w, err := cli.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return cli.Quit()
+}
+
+// Shutdown shuts down email action scheduler and returns abandoned events.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	return c.scheduler.Shutdown(ctx, grace)
+}
+
+// New returns an action for email.
+func New(cli *Client, ac model.ActionConfig) (*Email, error) {
+	t, err := ParseTemplates(ac.Email)
+	if err != nil {
+		return nil, err
+	}
+	from, err := mail.ParseAddress(ac.Email.From)
+	if err != nil {
+		return nil, err
+	}
+	to := make([]string, 0, len(ac.Email.To))
+	for _, addr := range ac.Email.To {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		to = append(to, a.Address)
+	}
+	return &Email{
+		cli:       cli,
+		name:      ac.Name,
+		from:      from,
+		to:        to,
+		attendees: ac.Email.Attendees,
+		templates: t,
+	}, nil
+}
+
+// List lists schedule events from email action scheduler.
+func (a *Email) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders emails of schedule events and registers them to email action scheduler.
+func (a *Email) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	for _, event := range events {
+		m, err := a.newMessage(event)
+		if err != nil {
+			return err
+		}
+		d, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := a.cli.scheduler.Register(a.name, event, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipients returns static recipients followed by attendees without duplicates.
+func (a *Email) recipients(event model.ScheduleEvent) []string {
+	to := make([]string, 0, len(a.to)+len(event.Attendees))
+	seen := make(map[string]struct{}, cap(to))
+	add := func(addrs []string) {
+		for _, addr := range addrs {
+			key := strings.ToLower(addr)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			to = append(to, addr)
+		}
+	}
+	add(a.to)
+	if a.attendees {
+		add(event.Attendees)
+	}
+	return to
+}
+
+func (a *Email) newMessage(event model.ScheduleEvent) (*message, error) {
+	data := render.NewData(a.name, event)
+	subject, err := render.Execute(a.templates.subject, data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := render.Execute(a.templates.text, data)
+	if err != nil {
+		return nil, err
+	}
+	var html string
+	if a.templates.html != nil {
+		var b strings.Builder
+		if err := a.templates.html.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		html = b.String()
+	}
+
+	to := a.recipients(event)
+	domain := a.from.Address[strings.LastIndex(a.from.Address, "@")+1:]
+	messageID := fmt.Sprintf("<%s.%d@%s>", event.ID("."), event.EventType, domain)
+	header := textproto.MIMEHeader{}
+	header.Set("From", a.from.String())
+	header.Set("To", strings.Join(to, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	header.Set("Message-ID", messageID)
+	header.Set("MIME-Version", "1.0")
+	body, err := buildBody(header, text, html)
+	if err != nil {
+		return nil, err
+	}
+	return &message{
+		From:      a.from.Address,
+		To:        to,
+		MessageID: messageID,
+		Data:      body,
+	}, nil
+}
+
+// buildBody builds headers and body of email.
+// It is multipart/alternative if HTML is given, otherwise text/plain.
+func buildBody(header textproto.MIMEHeader, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	if html == "" {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		if err := writeQuotedPrintable(&body, text); err != nil {
+			return nil, err
+		}
+	} else {
+		mw := multipart.NewWriter(&body)
+		header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+		for _, part := range []struct{ contentType, content string }{
+			{"text/plain; charset=utf-8", text},
+			{"text/html; charset=utf-8", html},
+		} {
+			w, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := writeQuotedPrintable(w, part.content); err != nil {
+				return nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	for _, k := range []string{"From", "To", "Subject", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(k); v != "" {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// Unregister unregisters schedule events from email action scheduler.
+func (a *Email) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
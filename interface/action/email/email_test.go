@@ -0,0 +1,267 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+var testEvent = model.ScheduleEvent{
+	ScheduleID: "sid",
+	Summary:    "on-call shift",
+	Attendees:  []string{"alice@example.com", "Bob@example.com"},
+	EventType:  model.Start,
+	ExecuteAt:  time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC),
+}
+
+func TestEmail_newMessage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		action        model.EmailAction
+		wantTo        []string
+		wantSubject   string
+		wantMultipart bool
+	}{
+		{
+			name: "static recipients",
+			action: model.EmailAction{
+				From: "Notifier <notifier@example.com>",
+				To:   []string{"On-call <oncall@example.com>"},
+			},
+			wantTo:      []string{"oncall@example.com"},
+			wantSubject: "on-call shift (Start)",
+		},
+		{
+			name: "template functions and action name are available",
+			action: model.EmailAction{
+				From:    "notifier@example.com",
+				To:      []string{"oncall@example.com"},
+				Subject: "[{{.ActionName}}] {{json .Summary}}",
+			},
+			wantTo:      []string{"oncall@example.com"},
+			wantSubject: `[notify] "on-call shift"`,
+		},
+		{
+			name: "attendees are added without duplicates",
+			action: model.EmailAction{
+				From:      "notifier@example.com",
+				To:        []string{"bob@example.com"},
+				Attendees: true,
+				Subject:   "シフト開始: {{.Summary}}",
+				HTML:      "<p>{{.Summary}}</p>",
+			},
+			wantTo:        []string{"bob@example.com", "alice@example.com"},
+			wantSubject:   "シフト開始: on-call shift",
+			wantMultipart: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := New(&Client{}, model.ActionConfig{Name: "notify", Email: tt.action})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := a.newMessage(testEvent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m.To, tt.wantTo) {
+				t.Errorf("want %v but got %v", tt.wantTo, m.To)
+			}
+			msg, err := mail.ReadMessage(strings.NewReader(string(m.Data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("want %q but got %q", tt.wantSubject, subject)
+			}
+			if msg.Header.Get("Message-ID") != m.MessageID {
+				t.Errorf("unexpected Message-ID: %s", msg.Header.Get("Message-ID"))
+			}
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantMultipart {
+				if mediaType != "text/plain" {
+					t.Errorf("unexpected media type: %s", mediaType)
+				}
+				return
+			}
+			if mediaType != "multipart/alternative" {
+				t.Fatalf("unexpected media type: %s", mediaType)
+			}
+			mr := multipart.NewReader(msg.Body, params["boundary"])
+			types := make([]string, 0, 2)
+			for {
+				p, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				types = append(types, p.Header.Get("Content-Type"))
+			}
+			want := []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}
+			if !reflect.DeepEqual(types, want) {
+				t.Errorf("want %v but got %v", want, types)
+			}
+		})
+	}
+}
+
+// smtpServer is a minimal SMTP server which accepts every message.
+type smtpServer struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tc := textproto.NewConn(conn)
+	_ = tc.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		s.mu.Lock()
+		switch cmd {
+		case "EHLO", "HELO":
+			_ = tc.PrintfLine("250 localhost")
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			_ = tc.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = append(s.to, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			_ = tc.PrintfLine("250 OK")
+		case "DATA":
+			_ = tc.PrintfLine("354 Go ahead")
+			d, err := tc.ReadDotBytes()
+			if err != nil {
+				s.mu.Unlock()
+				return
+			}
+			s.data = string(d)
+			_ = tc.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tc.PrintfLine("221 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			_ = tc.PrintfLine("250 OK")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	srv := newSMTPServer(t)
+	host, port, err := net.SplitHostPort(srv.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ac := model.ActionConfig{
+		Name: "notify",
+		Email: model.EmailAction{
+			SMTP:      model.SMTPConfig{Host: host, Port: p, Password: "secret", TLS: model.SMTPTLSNone},
+			From:      "notifier@example.com",
+			Attendees: true,
+		},
+	}
+	cli := &Client{configs: func(name model.ActionName) (model.ActionConfig, bool) {
+		return ac, name == ac.Name
+	}}
+	a, err := New(cli, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := a.newMessage(testEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(d), "secret") {
+		t.Fatalf("SMTP password should not be stored in payload: %s", d)
+	}
+
+	ctx, res := scheduler.WithResult(context.Background())
+	if err := a.cli.execute(ctx, &scheduler.Task{ActionName: "notify", Event: testEvent, Payload: d}); err != nil {
+		t.Fatal(err)
+	}
+	if res.MessageID != m.MessageID {
+		t.Errorf("want %s but got %s", m.MessageID, res.MessageID)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.from != "notifier@example.com" {
+		t.Errorf("unexpected sender: %s", srv.from)
+	}
+	if want := testEvent.Attendees; !reflect.DeepEqual(srv.to, want) {
+		t.Errorf("want %v but got %v", want, srv.to)
+	}
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(srv.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("Date") == "" {
+		t.Error("Date header should be set on sending")
+	}
+}
@@ -0,0 +1,272 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const (
+	namespace      = "exec"
+	defaultTimeout = time.Minute
+	// waitDelay is time to wait for output pipes to be closed after the command is killed.
+	waitDelay = time.Second
+	// maxOutputSize is the size of the tail of output which is kept for logs and history.
+	maxOutputSize = 4 << 10
+	envPrefix     = "CN_"
+)
+
+// Exec implements repository.Action for local command execution.
+type Exec struct {
+	cli     *Client
+	name    model.ActionName
+	command string
+	args    []string
+	dir     string
+	timeout time.Duration
+}
+
+// Client represents command executor.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+}
+
+// command is a command which is stored in scheduler.
+type command struct {
+	Command string          `json:"command"`
+	Args    []string        `json:"args,omitempty"`
+	Dir     string          `json:"dir,omitempty"`
+	Env     []string        `json:"env,omitempty"`
+	Timeout time.Duration   `json:"timeout"`
+	Input   json.RawMessage `json:"input"`
+}
+
+// input is schedule event which is passed to stdin of the command as JSON.
+type input struct {
+	ActionName  model.ActionName `json:"action_name"`
+	EventID     string           `json:"event_id"`
+	ScheduleID  string           `json:"schedule_id"`
+	Summary     string           `json:"summary"`
+	Description string           `json:"description"`
+	Attendees   []string         `json:"attendees"`
+	EventType   model.EventType  `json:"event_type"`
+	ExecuteAt   time.Time        `json:"execute_at"`
+}
+
+// env returns the event as CN_* environment variables.
+func (in input) env() []string {
+	et, _ := in.EventType.MarshalJSON()
+	return []string{
+		envPrefix + "ACTION_NAME=" + string(in.ActionName),
+		envPrefix + "EVENT_ID=" + in.EventID,
+		envPrefix + "SCHEDULE_ID=" + in.ScheduleID,
+		envPrefix + "SUMMARY=" + in.Summary,
+		envPrefix + "DESCRIPTION=" + in.Description,
+		envPrefix + "ATTENDEES=" + strings.Join(in.Attendees, ","),
+		envPrefix + "EVENT_TYPE=" + strings.Trim(string(et), `"`),
+		envPrefix + "EXECUTE_AT=" + in.ExecuteAt.Format(time.RFC3339),
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = b.buf[over:]
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	if b.truncated {
+		return "..." + string(b.buf)
+	}
+	return string(b.buf)
+}
+
+// NewClient returns command executor.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{configs: configs}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+// execute runs the command. Non-zero exit code is regarded as failure.
+// Environment variables are inherited from the process, and overridden by configured ones and CN_* ones.
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	cmd := &command{}
+	if err := json.Unmarshal(task.Payload, cmd); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	parent := ctx
+	if cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmd.Timeout)
+		defer cancel()
+	}
+
+	stdout := &tailBuffer{max: maxOutputSize}
+	stderr := &tailBuffer{max: maxOutputSize}
+	ec := exec.CommandContext(ctx, cmd.Command, cmd.Args...)
+	ec.Dir = cmd.Dir
+	ec.Env = append(append(os.Environ(), environ(ac.Exec.Env)...), cmd.Env...)
+	ec.Stdin = bytes.NewReader(cmd.Input)
+	ec.Stdout = stdout
+	ec.Stderr = stderr
+	// children of the command are killed together, and output of orphans holding the pipes is not waited for long
+	setProcessGroup(ec)
+	ec.Cancel = func() error { return killProcessGroup(ec) }
+	ec.WaitDelay = waitDelay
+	err = ec.Run()
+
+	res := scheduler.Result{Stdout: stdout.String(), Stderr: stderr.String()}
+	if ec.ProcessState != nil {
+		code := ec.ProcessState.ExitCode()
+		res.ExitCode = &code
+	}
+	scheduler.SetResult(ctx, res)
+	for _, out := range []struct{ name, s string }{{"stdout", res.Stdout}, {"stderr", res.Stderr}} {
+		if out.s != "" {
+			log.Printf("[exec action] %s, schedule_id: %s, %s: %s\n", task.ActionName, task.Event.ScheduleID, out.name, out.s)
+		}
+	}
+
+	if err := parent.Err(); err != nil {
+		return err
+	}
+	// timeout of the command is retryable unlike deadline of retry policy
+	if ctx.Err() != nil {
+		return fmt.Errorf("command timed out after %s", cmd.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		log.Println("[exec action] failed, exit code:", exitErr.ExitCode())
+		return permanent.New(fmt.Errorf("command exited with code %d", exitErr.ExitCode()))
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("[exec action] succeeded, command:", cmd.Command)
+	return nil
+}
+
+// Shutdown shuts down exec action scheduler and returns abandoned events.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	return c.scheduler.Shutdown(ctx, grace)
+}
+
+// New returns an action for local command execution.
+func New(cli *Client, ac model.ActionConfig) *Exec {
+	timeout := ac.Exec.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &Exec{
+		cli:     cli,
+		name:    ac.Name,
+		command: ac.Exec.Command,
+		args:    ac.Exec.Args,
+		dir:     ac.Exec.Dir,
+		timeout: timeout,
+	}
+}
+
+// List lists schedule events from exec action scheduler.
+func (a *Exec) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register registers schedule events to exec action scheduler.
+func (a *Exec) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	for _, event := range events {
+		cmd, err := a.newCommand(event)
+		if err != nil {
+			return err
+		}
+		d, err := json.Marshal(cmd)
+		if err != nil {
+			return err
+		}
+		if err := a.cli.scheduler.Register(a.name, event, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCommand returns command for the event with CN_* environment variables.
+func (a *Exec) newCommand(event model.ScheduleEvent) (*command, error) {
+	in := input{
+		ActionName:  a.name,
+		EventID:     event.ID(""),
+		ScheduleID:  event.ScheduleID,
+		Summary:     event.Summary,
+		Description: event.Description,
+		Attendees:   event.Attendees,
+		EventType:   event.EventType,
+		ExecuteAt:   event.ExecuteAt,
+	}
+	if in.Attendees == nil {
+		in.Attendees = []string{}
+	}
+	d, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	return &command{
+		Command: a.command,
+		Args:    a.args,
+		Dir:     a.dir,
+		Env:     in.env(),
+		Timeout: a.timeout,
+		Input:   d,
+	}, nil
+}
+
+// Unregister unregisters schedule events from exec action scheduler.
+func (a *Exec) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
+
+// environ returns configured environment variables in order of keys.
+func environ(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	vars := make([]string, 0, len(keys))
+	for _, k := range keys {
+		vars = append(vars, k+"="+env[k])
+	}
+	return vars
+}
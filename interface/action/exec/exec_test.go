@@ -0,0 +1,147 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+// newTestAction returns exec action whose client looks up ac.
+func newTestAction(ac model.ActionConfig) *Exec {
+	cli := &Client{configs: func(name model.ActionName) (model.ActionConfig, bool) {
+		return ac, name == ac.Name
+	}}
+	return New(cli, ac)
+}
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	event := model.ScheduleEvent{
+		ScheduleID: "sid",
+		Summary:    "backup",
+		EventType:  model.Start,
+		ExecuteAt:  time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name         string
+		action       model.ExecAction
+		wantErr      bool
+		wantExitCode int
+		wantStdout   string
+		wantStderr   string
+	}{
+		{
+			name: "event is passed by stdin and environment variables",
+			action: model.ExecAction{
+				Command: "sh",
+				Args:    []string{"-c", `read -r line; echo "$CN_SUMMARY $CN_EVENT_TYPE $GREETING"; echo "$line" >&2`},
+				Env:     map[string]string{"GREETING": "hello"},
+			},
+			wantExitCode: 0,
+			wantStdout:   "backup start hello\n",
+			wantStderr:   `{"action_name":"backup","event_id":"sid:1654077600","schedule_id":"sid","summary":"backup","description":"","attendees":[],"event_type":"start","execute_at":"2022-06-01T10:00:00Z"}` + "\n",
+		},
+		{
+			name: "non-zero exit code is failure",
+			action: model.ExecAction{
+				Command: "sh",
+				Args:    []string{"-c", "echo failed >&2; exit 3"},
+			},
+			wantErr:      true,
+			wantExitCode: 3,
+			wantStderr:   "failed\n",
+		},
+		{
+			name: "timeout",
+			action: model.ExecAction{
+				Command: "sleep",
+				Args:    []string{"10"},
+				Timeout: 100 * time.Millisecond,
+			},
+			wantErr:      true,
+			wantExitCode: -1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := newTestAction(model.ActionConfig{Name: "backup", Exec: tt.action})
+			cmd, err := a.newCommand(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := json.Marshal(cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(d), "GREETING=") {
+				t.Errorf("configured environment variables should not be stored in payload: %s", d)
+			}
+
+			ctx, res := scheduler.WithResult(context.Background())
+			err = a.cli.execute(ctx, &scheduler.Task{ActionName: a.name, Event: event, Payload: d})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.ExitCode == nil || *res.ExitCode != tt.wantExitCode {
+				t.Errorf("want exit code %d but got %v", tt.wantExitCode, res.ExitCode)
+			}
+			if res.Stdout != tt.wantStdout {
+				t.Errorf("want stdout %q but got %q", tt.wantStdout, res.Stdout)
+			}
+			if res.Stderr != tt.wantStderr {
+				t.Errorf("want stderr %q but got %q", tt.wantStderr, res.Stderr)
+			}
+		})
+	}
+}
+
+func TestClient_execute_timeoutWithChildren(t *testing.T) {
+	t.Parallel()
+	event := model.ScheduleEvent{ScheduleID: "sid", EventType: model.Start, ExecuteAt: time.Now()}
+	a := newTestAction(model.ActionConfig{Name: "backup", Exec: model.ExecAction{
+		Command: "sh",
+		// the child holds stdout and stderr after the shell is killed
+		Args:    []string{"-c", "sleep 5 & echo hi; wait"},
+		Timeout: 500 * time.Millisecond,
+	}})
+	cmd, err := a.newCommand(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	ctx, res := scheduler.WithResult(context.Background())
+	if err := a.cli.execute(ctx, &scheduler.Task{ActionName: a.name, Event: event, Payload: d}); err == nil {
+		t.Fatal("err should not be nil")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timeout should bound the run time: %s", elapsed)
+	}
+	if res.Stdout != "hi\n" {
+		t.Errorf("unexpected stdout: %q", res.Stdout)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	t.Parallel()
+	b := &tailBuffer{max: 4}
+	_, _ = b.Write([]byte("abc"))
+	_, _ = b.Write([]byte("def"))
+	if got := b.String(); got != "...cdef" {
+		t.Errorf("want %q but got %q", "...cdef", got)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package exec
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on platforms without process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command, and its children are left running on platforms without process groups.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,369 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+	"github.com/ww24/calendar-notifier/internal/tlsconfig"
+)
+
+const (
+	timeout   = 15 * time.Second
+	namespace = "grpc"
+	userAgent = "calendar-notifier"
+)
+
+// permanentCodes is status codes of calls which do not succeed by retrying.
+var permanentCodes = map[codes.Code]bool{
+	codes.InvalidArgument:    true,
+	codes.NotFound:           true,
+	codes.AlreadyExists:      true,
+	codes.PermissionDenied:   true,
+	codes.FailedPrecondition: true,
+	codes.OutOfRange:         true,
+	codes.Unimplemented:      true,
+	codes.Unauthenticated:    true,
+}
+
+// GRPC implements repository.Action for gRPC unary call.
+type GRPC struct {
+	cli     *Client
+	name    model.ActionName
+	method  string
+	payload map[string]interface{}
+	request *template.Template
+}
+
+// Client represents gRPC client which shares connections among actions.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+	conns     map[string]*grpclib.ClientConn
+	// methods caches method descriptors resolved by server reflection or descriptor set.
+	methods map[string]protoreflect.MethodDescriptor
+	mu      sync.Mutex
+}
+
+// conn identifies a connection to the target.
+type conn struct {
+	Target string           `json:"target"`
+	TLS    *model.TLSConfig `json:"tls,omitempty"`
+}
+
+// request is gRPC request which is stored in scheduler.
+type request struct {
+	Method string          `json:"method"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// ParseTemplate parses request template of gRPC action.
+func ParseTemplate(ga model.GRPCAction) (*template.Template, error) {
+	return render.Parse("request", ga.Request)
+}
+
+// ParseMethod parses full method name, package.Service/Method or package.Service.Method.
+func ParseMethod(name string) (service, method string, err error) {
+	name = strings.TrimPrefix(name, "/")
+	i := strings.LastIndexAny(name, "/.")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", fmt.Errorf("invalid method name: %q", name)
+	}
+	return name[:i], name[i+1:], nil
+}
+
+// LoadMethod loads descriptor of unary method from FileDescriptorSet file.
+func LoadMethod(descriptorSet, name string) (protoreflect.MethodDescriptor, error) {
+	d, err := os.ReadFile(descriptorSet)
+	if err != nil {
+		return nil, err
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(d, fds); err != nil {
+		return nil, fmt.Errorf("parse descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+	return findMethod(files, name)
+}
+
+func findMethod(files *protoregistry.Files, name string) (protoreflect.MethodDescriptor, error) {
+	service, method, err := ParseMethod(name)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %s: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("method %s is not found in %s", method, service)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("method %s is not unary", md.FullName())
+	}
+	return md, nil
+}
+
+// NewClient returns gRPC client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{
+		configs: configs,
+		conns:   make(map[string]*grpclib.ClientConn),
+		methods: make(map[string]protoreflect.MethodDescriptor),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+// conn returns connection to the target, creating it on first use.
+func (c *Client) conn(cn conn) (*grpclib.ClientConn, error) {
+	key, err := json.Marshal(cn)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cc, ok := c.conns[string(key)]; ok {
+		return cc, nil
+	}
+	creds := insecure.NewCredentials()
+	if cn.TLS != nil {
+		cnf, err := tlsconfig.New(*cn.TLS)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(cnf)
+	}
+	cc, err := grpclib.Dial(cn.Target,
+		grpclib.WithTransportCredentials(creds),
+		grpclib.WithUserAgent(userAgent),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", cn.Target, err)
+	}
+	c.conns[string(key)] = cc
+	return cc, nil
+}
+
+// method returns descriptor of the method.
+// It is loaded from descriptor set if it is configured, otherwise resolved by server reflection.
+func (c *Client) method(ctx context.Context, cc *grpclib.ClientConn, ga model.GRPCAction, name string) (protoreflect.MethodDescriptor, error) {
+	key := ga.Target + "\x00" + ga.DescriptorSet + "\x00" + name
+	c.mu.Lock()
+	md, ok := c.methods[key]
+	c.mu.Unlock()
+	if ok {
+		return md, nil
+	}
+
+	var err error
+	if ga.DescriptorSet != "" {
+		md, err = LoadMethod(ga.DescriptorSet, name)
+	} else {
+		md, err = reflectMethod(ctx, cc, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.methods[key] = md
+	c.mu.Unlock()
+	return md, nil
+}
+
+// reflectMethod resolves descriptor of the method by server reflection.
+func reflectMethod(ctx context.Context, cc *grpclib.ClientConn, name string) (protoreflect.MethodDescriptor, error) {
+	service, _, err := ParseMethod(name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := rpb.NewServerReflectionClient(cc).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("server reflection: %w", err)
+	}
+	// files and dependencies which are not sent by the server are requested by file name
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	req := &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}
+	for req != nil {
+		if err := stream.Send(req); err != nil {
+			return nil, fmt.Errorf("server reflection: %w", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, fmt.Errorf("server reflection: %w", err)
+		}
+		if er := resp.GetErrorResponse(); er != nil {
+			return nil, status.Error(codes.Code(er.GetErrorCode()), "server reflection: "+er.GetErrorMessage())
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return nil, fmt.Errorf("server reflection: %w", err)
+			}
+			files[fd.GetName()] = fd
+		}
+		req = nil
+		for _, fd := range files {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := files[dep]; !ok {
+					req = &rpb.ServerReflectionRequest{
+						MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+					}
+				}
+			}
+		}
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range files {
+		fds.File = append(fds.File, fd)
+	}
+	reg, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+	return findMethod(reg, name)
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	r := &request{}
+	if err := json.Unmarshal(task.Payload, r); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	cc, err := c.conn(conn{Target: ac.GRPC.Target, TLS: ac.GRPC.TLS})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	md, err := c.method(ctx, cc, ac.GRPC, r.Method)
+	if err != nil {
+		return err
+	}
+
+	in := dynamicpb.NewMessage(md.Input())
+	if err := protojson.Unmarshal(r.Body, in); err != nil {
+		return permanent.New(fmt.Errorf("request of %s: %w", md.FullName(), err))
+	}
+	out := dynamicpb.NewMessage(md.Output())
+	if len(ac.GRPC.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(ac.GRPC.Metadata))
+	}
+	fullMethod := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+	if err := cc.Invoke(ctx, fullMethod, in, out); err != nil {
+		if permanentCodes[status.Code(err)] {
+			return permanent.New(err)
+		}
+		return err
+	}
+	log.Println("[grpc action] called, method:", fullMethod)
+	return nil
+}
+
+// Shutdown shuts down gRPC action scheduler and returns abandoned events.
+// Connections are closed after scheduled events are called.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	abandoned := c.scheduler.Shutdown(ctx, grace)
+	c.mu.Lock()
+	for key, cc := range c.conns {
+		if err := cc.Close(); err != nil {
+			log.Println("[grpc action] close error:", err)
+		}
+		delete(c.conns, key)
+	}
+	c.mu.Unlock()
+	return abandoned
+}
+
+// New returns an action for gRPC unary call.
+func New(cli *Client, ac model.ActionConfig) (*GRPC, error) {
+	t, err := ParseTemplate(ac.GRPC)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPC{
+		cli:     cli,
+		name:    ac.Name,
+		method:  ac.GRPC.Method,
+		payload: ac.Payload,
+		request: t,
+	}, nil
+}
+
+// List lists schedule events from gRPC action scheduler.
+func (a *GRPC) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders requests of schedule events and registers them to gRPC action scheduler.
+func (a *GRPC) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	return render.Register(a.cli.scheduler, a.name, events, func(event model.ScheduleEvent) (interface{}, error) {
+		return a.newRequest(event)
+	})
+}
+
+func (a *GRPC) newRequest(event model.ScheduleEvent) (*request, error) {
+	// request is empty message if neither request nor payload is set
+	body, err := render.Body(a.request, render.NewData(a.name, event), a.payload, struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("request is not valid JSON: %s", body)
+	}
+	return &request{
+		Method: a.method,
+		Body:   body,
+	}, nil
+}
+
+// Unregister unregisters schedule events from gRPC action scheduler.
+func (a *GRPC) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
@@ -0,0 +1,198 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const checkMethod = "grpc.health.v1.Health/Check"
+
+// runServer runs health server which records metadata of calls.
+func runServer(t *testing.T) (string, chan metadata.MD) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mds := make(chan metadata.MD, 10)
+	s := grpclib.NewServer(grpclib.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			mds <- md
+			return handler(ctx, req)
+		},
+	))
+	hs := health.NewServer()
+	hs.SetServingStatus("light", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+	return ln.Addr().String(), mds
+}
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	c := &Client{
+		conns:   make(map[string]*grpclib.ClientConn),
+		methods: make(map[string]protoreflect.MethodDescriptor),
+	}
+	t.Cleanup(func() {
+		for _, cc := range c.conns {
+			cc.Close()
+		}
+	})
+	return c
+}
+
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	fds := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)},
+	}
+	d, err := proto.Marshal(fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "health.pb")
+	if err := os.WriteFile(path, d, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	target, mds := runServer(t)
+	descriptorSet := writeDescriptorSet(t)
+	tests := []struct {
+		name          string
+		descriptorSet string
+		summary       string
+		wantCode      codes.Code
+		wantPermanent bool
+	}{
+		{
+			name:    "method is resolved by server reflection",
+			summary: "light",
+		},
+		{
+			name:          "method is resolved by descriptor set",
+			descriptorSet: descriptorSet,
+			summary:       "light",
+		},
+		{
+			name:          "non-OK status is failure",
+			summary:       "unknown",
+			wantCode:      codes.NotFound,
+			wantPermanent: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ac := model.ActionConfig{
+				Name: "light_on",
+				GRPC: model.GRPCAction{
+					Target:        target,
+					Method:        checkMethod,
+					DescriptorSet: tt.descriptorSet,
+					Request:       `{"service": {{json .Summary}}}`,
+					Metadata:      map[string]string{"authorization": "Bearer secret"},
+				},
+			}
+			c := newTestClient(t)
+			c.configs = func(name model.ActionName) (model.ActionConfig, bool) {
+				return ac, name == ac.Name
+			}
+			a, err := New(c, ac)
+			if err != nil {
+				t.Fatal(err)
+			}
+			event := model.ScheduleEvent{ScheduleID: "sid", Summary: tt.summary, EventType: model.Start}
+			r, err := a.newRequest(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(d), "secret") || strings.Contains(string(d), target) {
+				t.Errorf("metadata and target should not be stored in payload: %s", d)
+			}
+			err = a.cli.execute(context.Background(), &scheduler.Task{ActionName: a.name, Event: event, Payload: d})
+			if got := permanent.Is(err); got != tt.wantPermanent {
+				t.Fatalf("want permanent %t but got %t", tt.wantPermanent, got)
+			}
+			if tt.wantPermanent {
+				err = errors.Unwrap(err)
+			}
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("want %s but got %v", tt.wantCode, err)
+			}
+			md := <-mds
+			if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer secret" {
+				t.Errorf("unexpected metadata: %v", md)
+			}
+		})
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		method      string
+		wantService string
+		wantMethod  string
+		wantErr     bool
+	}{
+		{name: "slash separated", method: "home.v1.Light/Switch", wantService: "home.v1.Light", wantMethod: "Switch"},
+		{name: "leading slash", method: "/home.v1.Light/Switch", wantService: "home.v1.Light", wantMethod: "Switch"},
+		{name: "dot separated", method: "home.v1.Light.Switch", wantService: "home.v1.Light", wantMethod: "Switch"},
+		{name: "method only", method: "Switch", wantErr: true},
+		{name: "empty method", method: "home.v1.Light/", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			service, method, err := ParseMethod(tt.method)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err should not be nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if service != tt.wantService || method != tt.wantMethod {
+				t.Errorf("want %s %s but got %s %s", tt.wantService, tt.wantMethod, service, method)
+			}
+		})
+	}
+}
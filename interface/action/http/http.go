@@ -4,25 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"io"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
 	"github.com/ww24/calendar-notifier/internal/scheduler"
 )
 
 const (
 	timeout           = 15 * time.Second
 	contentTypeHeader = "Content-Type"
+	namespace         = "http"
 )
 
-// HTTP implements respository.Action for http request.
+// HTTP implements repository.Action for http request.
 type HTTP struct {
 	cli     *Client
 	name    model.ActionName
-	header  http.Header
 	method  string
 	url     string
 	payload map[string]interface{}
@@ -32,26 +33,85 @@ type HTTP struct {
 type Client struct {
 	cli       *http.Client
 	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+}
+
+// StatusError is error which represents non-2xx response.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected response status: " + e.Status
+}
+
+// request is serialized http request which is stored in scheduler.
+type request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   []byte `json:"body,omitempty"`
 }
 
 // NewClient returns http client.
-func NewClient(ctx context.Context) (*Client, error) {
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
 	cli := &http.Client{
 		Timeout: timeout,
 	}
 	c := &Client{
-		cli:       cli,
-		scheduler: scheduler.NewInMemory(ctx),
+		cli:     cli,
+		configs: configs,
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
 	}
+	c.scheduler = s
 	return c, nil
 }
 
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	r := &request{}
+	if err := json.Unmarshal(task.Payload, r); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return err
+	}
+	if ac.Header != nil {
+		req.Header = ac.Header.Clone()
+	}
+	if r.Body != nil && req.Header.Get(contentTypeHeader) == "" {
+		req.Header.Set(contentTypeHeader, "application/json")
+	}
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	log.Println("[http action] sent, status:", resp.Status)
+	scheduler.SetResult(ctx, scheduler.Result{StatusCode: resp.StatusCode})
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+// Shutdown shuts down http action scheduler and returns abandoned events.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	return c.scheduler.Shutdown(ctx, grace)
+}
+
 // New returns an action for http.
 func New(cli *Client, ac model.ActionConfig) *HTTP {
 	return &HTTP{
 		cli:     cli,
 		name:    ac.Name,
-		header:  ac.Header,
 		method:  ac.Method,
 		url:     ac.URL,
 		payload: ac.Payload,
@@ -70,40 +130,33 @@ func (a *HTTP) Register(_ context.Context, events ...model.ScheduleEvent) error
 		if err != nil {
 			return err
 		}
-		err = a.cli.scheduler.Register(a.name, event, func(ctx context.Context) error {
-			resp, err := a.cli.cli.Do(req.WithContext(ctx))
-			if err != nil {
-				return err
-			}
-			log.Println("[http action] sent, status:", resp.Status)
-			return nil
-		})
+		d, err := json.Marshal(req)
 		if err != nil {
 			return err
 		}
+		if err := a.cli.scheduler.Register(a.name, event, d); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (a *HTTP) newRequest(events model.ScheduleEvent) (*http.Request, error) {
-	var body io.Reader
+func (a *HTTP) newRequest(events model.ScheduleEvent) (*request, error) {
+	// validate method and url
+	if _, err := http.NewRequest(a.method, a.url, nil); err != nil {
+		return nil, err
+	}
+	req := &request{
+		Method: a.method,
+		URL:    a.url,
+	}
 	if a.payload != nil {
 		b := &bytes.Buffer{}
 		e := json.NewEncoder(b)
 		if err := e.Encode(a.payload); err != nil {
 			return nil, err
 		}
-		body = b
-	}
-	req, err := http.NewRequest(a.method, a.url, body)
-	if err != nil {
-		return nil, err
-	}
-	if a.header != nil {
-		req.Header = a.header
-	}
-	if body != nil && req.Header.Get(contentTypeHeader) == "" {
-		req.Header.Set(contentTypeHeader, "application/json")
+		req.Body = b.Bytes()
 	}
 	return req, nil
 }
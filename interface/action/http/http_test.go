@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	headers := make(chan http.Header, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+	}))
+	t.Cleanup(srv.Close)
+
+	ac := model.ActionConfig{
+		Name: "light_on",
+		HTTPRequestAction: model.HTTPRequestAction{
+			Method: http.MethodPost,
+			URL:    srv.URL,
+			Header: http.Header{"Authorization": []string{"Bearer secret"}},
+		},
+		Payload: map[string]interface{}{"state": "ON"},
+	}
+	cli := &Client{cli: srv.Client(), configs: func(name model.ActionName) (model.ActionConfig, bool) {
+		return ac, name == ac.Name
+	}}
+	a := New(cli, ac)
+	event := model.ScheduleEvent{ScheduleID: "sid", EventType: model.Start, ExecuteAt: time.Now()}
+	req, err := a.newRequest(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(d), "secret") {
+		t.Errorf("header should not be stored in payload: %s", d)
+	}
+
+	if err := cli.execute(context.Background(), &scheduler.Task{ActionName: a.name, Event: event, Payload: d}); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	h := <-headers
+	if got := h.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("want authorization header but got %q", got)
+	}
+	if got := h.Get(contentTypeHeader); got != "application/json" {
+		t.Errorf("want content type application/json but got %q", got)
+	}
+}
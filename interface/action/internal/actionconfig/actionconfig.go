@@ -0,0 +1,25 @@
+package actionconfig
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+)
+
+// ErrNotFound is returned when action is not defined in config, e.g. it has been removed.
+var ErrNotFound = errors.New("action not found")
+
+// Lookup returns action config of the running process by action name.
+// Connection settings and credentials are looked up on execution of scheduled events,
+// so that they are not persisted in payloads of scheduler.
+type Lookup func(model.ActionName) (model.ActionConfig, bool)
+
+// Get returns action config, or permanent ErrNotFound if action is not defined.
+func (l Lookup) Get(name model.ActionName) (model.ActionConfig, error) {
+	if ac, ok := l(name); ok {
+		return ac, nil
+	}
+	return model.ActionConfig{}, permanent.New(fmt.Errorf("%w: %s", ErrNotFound, name))
+}
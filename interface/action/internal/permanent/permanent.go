@@ -0,0 +1,31 @@
+// Package permanent marks errors of actions which do not succeed by retrying, e.g. broken payloads and rejected requests.
+package permanent
+
+import "errors"
+
+// Error is error which is not retried by retry policy of actions.
+type Error struct {
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New marks err as permanent. It returns nil if err is nil.
+func New(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Err: err}
+}
+
+// Is reports whether err or any error wrapped by it is permanent.
+func Is(err error) bool {
+	var pe *Error
+	return errors.As(err, &pe)
+}
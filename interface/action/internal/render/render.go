@@ -0,0 +1,101 @@
+// Package render renders templates of actions with schedule events, so that every action provides the same variables and functions.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"text/template"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+// Funcs is functions available in templates.
+var Funcs = template.FuncMap{
+	// json encodes value as JSON to embed it in JSON message safely.
+	"json": func(v interface{}) (string, error) {
+		d, err := json.Marshal(v)
+		return string(d), err
+	},
+}
+
+// Data is data to render templates.
+type Data struct {
+	ActionName  model.ActionName
+	ScheduleID  string
+	Summary     string
+	Description string
+	Attendees   []string
+	EventType   string
+	ExecuteAt   time.Time
+}
+
+// NewData returns data to render templates with the schedule event.
+func NewData(name model.ActionName, event model.ScheduleEvent) Data {
+	return Data{
+		ActionName:  name,
+		ScheduleID:  event.ScheduleID,
+		Summary:     event.Summary,
+		Description: event.Description,
+		Attendees:   event.Attendees,
+		EventType:   event.EventType.String(),
+		ExecuteAt:   event.ExecuteAt,
+	}
+}
+
+// Parse parses template with Funcs. It returns nil if text is empty.
+func Parse(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Funcs(Funcs).Parse(text)
+}
+
+// Execute renders template with data. It returns empty string if t is nil.
+func Execute(t *template.Template, data Data) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Body renders message body with template t if it is set,
+// otherwise payload, or fallback if payload is not set either, is encoded as JSON.
+func Body(t *template.Template, data Data, payload map[string]interface{}, fallback interface{}) ([]byte, error) {
+	switch {
+	case t != nil:
+		s, err := Execute(t, data)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	case payload != nil:
+		return json.Marshal(payload)
+	default:
+		return json.Marshal(fallback)
+	}
+}
+
+// Register builds messages of schedule events by newMessage and registers them to scheduler as JSON.
+func Register(s scheduler.Scheduler, name model.ActionName, events []model.ScheduleEvent,
+	newMessage func(model.ScheduleEvent) (interface{}, error)) error {
+	for _, event := range events {
+		m, err := newMessage(event)
+		if err != nil {
+			return err
+		}
+		d, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := s.Register(name, event, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
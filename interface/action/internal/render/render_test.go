@@ -0,0 +1,122 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+func TestBody(t *testing.T) {
+	t.Parallel()
+	executeAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	event := model.ScheduleEvent{ScheduleID: "sid", Summary: `"light"`, EventType: model.Start, ExecuteAt: executeAt}
+	tests := []struct {
+		name     string
+		template string
+		payload  map[string]interface{}
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "template is rendered with the event",
+			template: "{{.ActionName}} {{.ScheduleID}} {{.EventType}} {{.ExecuteAt.Unix}}",
+			payload:  map[string]interface{}{"state": "ON"},
+			want:     "light sid Start 1654077600",
+		},
+		{
+			name:     "json function escapes value",
+			template: `{"summary": {{json .Summary}}}`,
+			want:     `{"summary": "\"light\""}`,
+		},
+		{
+			name:    "payload is encoded as JSON without template",
+			payload: map[string]interface{}{"state": "ON"},
+			want:    `{"state":"ON"}`,
+		},
+		{
+			name: "fallback is encoded as JSON without template and payload",
+			want: `{"schedule_id":"sid"}`,
+		},
+		{
+			name:     "template fails to render",
+			template: "{{.Unknown}}",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpl, err := Parse("message", tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fallback := map[string]string{"schedule_id": event.ScheduleID}
+			got, err := Body(tmpl, NewData("light", event), tt.payload, fallback)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err should not be nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecute_empty(t *testing.T) {
+	t.Parallel()
+	tmpl, err := Parse("topic", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl != nil {
+		t.Fatal("template should be nil for empty text")
+	}
+	got, err := Execute(tmpl, Data{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("want empty string but got %q", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	s := scheduler.NewInMemory(ctx, func(context.Context, *scheduler.Task) error { return nil })
+	t.Cleanup(func() { s.Shutdown(ctx, 0) })
+	executeAt := time.Now().Add(time.Hour)
+	events := []model.ScheduleEvent{
+		{ScheduleID: "sid1", EventType: model.Start, ExecuteAt: executeAt},
+		{ScheduleID: "sid2", EventType: model.Start, ExecuteAt: executeAt},
+	}
+	errFailed := errors.New("failed")
+	err := Register(s, "light", events, func(event model.ScheduleEvent) (interface{}, error) {
+		if event.ScheduleID == "sid2" {
+			return nil, errFailed
+		}
+		return map[string]string{"id": event.ScheduleID}, nil
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("want %v but got %v", errFailed, err)
+	}
+	// events before the failure are registered
+	registered, err := s.List("light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registered) != 1 || registered[0].ScheduleID != "sid1" {
+		t.Errorf("unexpected events: %+v", registered)
+	}
+}
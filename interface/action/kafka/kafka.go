@@ -0,0 +1,297 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+	"github.com/ww24/calendar-notifier/internal/tlsconfig"
+)
+
+const (
+	timeout   = 15 * time.Second
+	namespace = "kafka"
+	clientID  = "calendar-notifier"
+	// batchTimeout is short because each event is produced synchronously.
+	batchTimeout = 10 * time.Millisecond
+)
+
+// Kafka implements repository.Action for Kafka produce.
+type Kafka struct {
+	cli       *Client
+	name      model.ActionName
+	topic     string
+	payload   map[string]interface{}
+	templates *Templates
+}
+
+// Client represents Kafka client which shares producers among actions.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+	writers   map[string]*kafka.Writer
+	mu        sync.Mutex
+}
+
+// producer identifies a producer to the brokers.
+type producer struct {
+	Brokers []string         `json:"brokers"`
+	Acks    string           `json:"acks"`
+	SASL    model.KafkaSASL  `json:"sasl"`
+	TLS     *model.TLSConfig `json:"tls,omitempty"`
+}
+
+func newProducer(ka model.KafkaAction) producer {
+	return producer{
+		Brokers: ka.Brokers,
+		Acks:    ka.Acks,
+		SASL:    ka.SASL,
+		TLS:     ka.TLS,
+	}
+}
+
+// message is Kafka message which is stored in scheduler.
+type message struct {
+	Topic   string            `json:"topic"`
+	Key     string            `json:"key"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Value   []byte            `json:"value"`
+}
+
+// Templates is message and headers templates of Kafka action.
+type Templates struct {
+	message *template.Template
+	headers map[string]*template.Template
+}
+
+// ParseTemplates parses message and headers templates of Kafka action.
+func ParseTemplates(ka model.KafkaAction) (*Templates, error) {
+	t := &Templates{headers: make(map[string]*template.Template, len(ka.Headers))}
+	var err error
+	if t.message, err = render.Parse("message", ka.Message); err != nil {
+		return nil, err
+	}
+	for k, v := range ka.Headers {
+		tmpl, err := render.Parse(k, v)
+		if err != nil {
+			return nil, err
+		}
+		t.headers[k] = tmpl
+	}
+	return t, nil
+}
+
+// ParseAcks parses required acks, "all", "one" or "none".
+// Empty acks is parsed as "all".
+func ParseAcks(acks string) (kafka.RequiredAcks, error) {
+	if acks == "" {
+		return kafka.RequireAll, nil
+	}
+	var ra kafka.RequiredAcks
+	if err := ra.UnmarshalText([]byte(acks)); err != nil {
+		return 0, err
+	}
+	return ra, nil
+}
+
+func newMechanism(s model.KafkaSASL) (sasl.Mechanism, error) {
+	switch s.Mechanism {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: s.Username, Password: s.Password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, s.Username, s.Password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, s.Username, s.Password)
+	}
+	return nil, fmt.Errorf("unsupported sasl mechanism: %s", s.Mechanism)
+}
+
+// NewClient returns Kafka client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{
+		configs: configs,
+		writers: make(map[string]*kafka.Writer),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+// writer returns producer to the brokers, creating it on first use.
+// Writer connects to the brokers lazily and is shared by actions with the same producer.
+func (c *Client) writer(p producer) (*kafka.Writer, error) {
+	key, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if w, ok := c.writers[string(key)]; ok {
+		return w, nil
+	}
+
+	acks, err := ParseAcks(p.Acks)
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := newMechanism(p.SASL)
+	if err != nil {
+		return nil, err
+	}
+	transport := &kafka.Transport{
+		DialTimeout: timeout,
+		ClientID:    clientID,
+		SASL:        mechanism,
+	}
+	if p.TLS != nil {
+		cnf, err := tlsconfig.New(*p.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLS = cnf
+	}
+	w := &kafka.Writer{
+		Addr: kafka.TCP(p.Brokers...),
+		// the same key is produced to the same partition to keep the order of events of a schedule
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: acks,
+		// failed deliveries are retried by scheduler
+		MaxAttempts:  1,
+		BatchTimeout: batchTimeout,
+		WriteTimeout: timeout,
+		Transport:    transport,
+	}
+	c.writers[string(key)] = w
+	return w, nil
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	w, err := c.writer(newProducer(ac.Kafka))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	msg := kafka.Message{
+		Topic:   m.Topic,
+		Key:     []byte(m.Key),
+		Value:   m.Value,
+		Headers: make([]kafka.Header, 0, len(m.Headers)),
+	}
+	for k, v := range m.Headers {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	sort.Slice(msg.Headers, func(i, j int) bool { return msg.Headers[i].Key < msg.Headers[j].Key })
+	if err := w.WriteMessages(ctx, msg); err != nil {
+		var werr kafka.WriteErrors
+		if errors.As(err, &werr) && len(werr) == 1 && werr[0] != nil {
+			return werr[0]
+		}
+		return err
+	}
+	log.Printf("[kafka action] produced, topic: %s, key: %s\n", m.Topic, m.Key)
+	return nil
+}
+
+// Shutdown shuts down Kafka action scheduler and returns abandoned events.
+// Producers are closed after scheduled events are produced.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	abandoned := c.scheduler.Shutdown(ctx, grace)
+	c.mu.Lock()
+	for key, w := range c.writers {
+		if err := w.Close(); err != nil {
+			log.Println("[kafka action] close error:", err)
+		}
+		delete(c.writers, key)
+	}
+	c.mu.Unlock()
+	return abandoned
+}
+
+// New returns an action for Kafka produce.
+func New(cli *Client, ac model.ActionConfig) (*Kafka, error) {
+	t, err := ParseTemplates(ac.Kafka)
+	if err != nil {
+		return nil, err
+	}
+	return &Kafka{
+		cli:       cli,
+		name:      ac.Name,
+		topic:     ac.Kafka.Topic,
+		payload:   ac.Payload,
+		templates: t,
+	}, nil
+}
+
+// List lists schedule events from Kafka action scheduler.
+func (a *Kafka) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders messages of schedule events and registers them to Kafka action scheduler.
+func (a *Kafka) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	return render.Register(a.cli.scheduler, a.name, events, func(event model.ScheduleEvent) (interface{}, error) {
+		return a.newMessage(event)
+	})
+}
+
+func (a *Kafka) newMessage(event model.ScheduleEvent) (*message, error) {
+	data := render.NewData(a.name, event)
+	var headers map[string]string
+	if len(a.templates.headers) > 0 {
+		headers = make(map[string]string, len(a.templates.headers))
+		for k, tmpl := range a.templates.headers {
+			v, err := render.Execute(tmpl, data)
+			if err != nil {
+				return nil, err
+			}
+			headers[k] = v
+		}
+	}
+
+	value, err := render.Body(a.templates.message, data, a.payload, event)
+	if err != nil {
+		return nil, err
+	}
+	return &message{
+		Topic:   a.topic,
+		Key:     event.ScheduleID,
+		Headers: headers,
+		Value:   value,
+	}, nil
+}
+
+// Unregister unregisters schedule events from Kafka action scheduler.
+func (a *Kafka) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+func TestKafka_newMessage(t *testing.T) {
+	t.Parallel()
+	event := model.ScheduleEvent{ScheduleID: "sid", Summary: "light", EventType: model.End}
+	tests := []struct {
+		name        string
+		action      model.KafkaAction
+		wantHeaders map[string]string
+		wantValue   string
+	}{
+		{
+			name:      "headers are not set by default",
+			action:    model.KafkaAction{Topic: "home", Message: "{{.Summary}}"},
+			wantValue: "light",
+		},
+		{
+			name: "message and headers are rendered",
+			action: model.KafkaAction{
+				Topic:   "home",
+				Message: "{{.Summary}} {{.EventType}}",
+				Headers: map[string]string{"event_type": "{{.EventType}}", "action": "{{.ActionName}}"},
+			},
+			wantHeaders: map[string]string{"event_type": "End", "action": "light"},
+			wantValue:   "light End",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := New(&Client{}, model.ActionConfig{Name: "light", Kafka: tt.action})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := a.newMessage(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Topic != "home" || m.Key != "sid" {
+				t.Errorf("unexpected topic and key: %s %s", m.Topic, m.Key)
+			}
+			if !reflect.DeepEqual(m.Headers, tt.wantHeaders) {
+				t.Errorf("want headers %v but got %v", tt.wantHeaders, m.Headers)
+			}
+			if string(m.Value) != tt.wantValue {
+				t.Errorf("want value %s but got %s", tt.wantValue, m.Value)
+			}
+		})
+	}
+}
+
+func TestClient_writer(t *testing.T) {
+	t.Parallel()
+	c := &Client{writers: make(map[string]*kafka.Writer)}
+	p := producer{
+		Brokers: []string{"localhost:9092"},
+		SASL:    model.KafkaSASL{Mechanism: "scram-sha-256", Username: "user", Password: "pass"},
+	}
+	w1, err := c.writer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w1.RequiredAcks != kafka.RequireAll {
+		t.Errorf("acks should be all by default: %v", w1.RequiredAcks)
+	}
+	w2, err := c.writer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w1 != w2 {
+		t.Error("writer should be shared by the same producer")
+	}
+	p.Acks = "one"
+	w3, err := c.writer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w1 == w3 {
+		t.Error("writer should not be shared by different acks")
+	}
+	p.SASL.Mechanism = "gssapi"
+	if _, err := c.writer(p); err == nil {
+		t.Error("err should not be nil for unsupported mechanism")
+	}
+}
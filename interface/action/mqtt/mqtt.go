@@ -0,0 +1,304 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+	"github.com/ww24/calendar-notifier/internal/tlsconfig"
+)
+
+const (
+	timeout         = 15 * time.Second
+	namespace       = "mqtt"
+	disconnectQuiet = 250 // milliseconds
+)
+
+// MQTT implements repository.Action for MQTT publish.
+type MQTT struct {
+	cli       *Client
+	name      model.ActionName
+	qos       byte
+	retain    bool
+	payload   map[string]interface{}
+	templates *Templates
+}
+
+// Client represents MQTT client which pools connections to brokers.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+	// clientID is prefix of client IDs of connections which client ID is not configured.
+	clientID string
+	conns    map[broker]*connection
+	mu       sync.Mutex
+}
+
+// connection is connection to the broker, which is locked while connecting
+// so that connecting to a broker does not block publishing to the other brokers.
+type connection struct {
+	cli paho.Client
+	mu  sync.Mutex
+}
+
+// broker identifies a connection to the broker.
+type broker struct {
+	URL      string          `json:"url"`
+	ClientID string          `json:"client_id,omitempty"`
+	Username string          `json:"username,omitempty"`
+	Password string          `json:"password,omitempty"`
+	TLS      model.TLSConfig `json:"tls"`
+}
+
+// message is MQTT message which is stored in scheduler.
+type message struct {
+	Topic   string `json:"topic"`
+	QoS     byte   `json:"qos"`
+	Retain  bool   `json:"retain"`
+	Payload []byte `json:"payload"`
+}
+
+// Templates is topic and message templates of MQTT action.
+type Templates struct {
+	topic   *template.Template
+	message *template.Template
+}
+
+// ParseTemplates parses topic and message templates of MQTT action.
+func ParseTemplates(ma model.MQTTAction) (*Templates, error) {
+	t := &Templates{}
+	var err error
+	if t.topic, err = render.Parse("topic", ma.Topic); err != nil {
+		return nil, err
+	}
+	if t.message, err = render.Parse("message", ma.Message); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// defaultClientID returns client ID which is unique to the process.
+func defaultClientID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return fmt.Sprintf("calendar-notifier-%s-%d", host, os.Getpid())
+}
+
+// NewClient returns MQTT client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{
+		configs:  configs,
+		clientID: defaultClientID(),
+		conns:    make(map[broker]*connection),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+// conn returns connection to the broker, connecting it on first use.
+// Connection reconnects automatically once it has been established.
+func (c *Client) conn(b broker) (paho.Client, error) {
+	c.mu.Lock()
+	cn, ok := c.conns[b]
+	if !ok {
+		cn = &connection{}
+		c.conns[b] = cn
+	}
+	c.mu.Unlock()
+
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	if cn.cli != nil {
+		return cn.cli, nil
+	}
+	clientID, err := c.connClientID(b)
+	if err != nil {
+		return nil, err
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(b.URL).
+		SetClientID(clientID).
+		SetUsername(b.Username).
+		SetPassword(b.Password).
+		SetConnectTimeout(timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(false)
+	if isTLS(b.URL) {
+		cnf, err := tlsconfig.New(b.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(cnf)
+	}
+	cli := paho.NewClient(opts)
+	token := cli.Connect()
+	if !token.WaitTimeout(timeout) {
+		cli.Disconnect(0)
+		return nil, fmt.Errorf("connect to %s: timed out", b.URL)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", b.URL, err)
+	}
+	log.Println("[mqtt action] connected, broker:", b.URL)
+	cn.cli = cli
+	return cli, nil
+}
+
+// connClientID returns client ID of the connection.
+// Default client ID is unique to the connection, so that connections to the same broker
+// with different credentials do not take over the session of each other.
+func (c *Client) connClientID(b broker) (string, error) {
+	if b.ClientID != "" {
+		return b.ClientID, nil
+	}
+	key, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key)
+	return c.clientID + "-" + hex.EncodeToString(sum[:4]), nil
+}
+
+func newBroker(ma model.MQTTAction) broker {
+	return broker{
+		URL:      ma.Broker,
+		ClientID: ma.ClientID,
+		Username: ma.Username,
+		Password: ma.Password,
+		TLS:      ma.TLS,
+	}
+}
+
+func isTLS(broker string) bool {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "ssl", "tls", "mqtts", "tcps", "wss":
+		return true
+	}
+	return false
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	cli, err := c.conn(newBroker(ac.MQTT))
+	if err != nil {
+		return err
+	}
+	token := cli.Publish(m.Topic, m.QoS, m.Retain, m.Payload)
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(timeout):
+		return errors.New("publish timed out")
+	}
+	if err := token.Error(); err != nil {
+		return err
+	}
+	log.Println("[mqtt action] published, topic:", m.Topic)
+	return nil
+}
+
+// Shutdown shuts down MQTT action scheduler and returns abandoned events.
+// Connections to brokers are closed after scheduled events are published.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	abandoned := c.scheduler.Shutdown(ctx, grace)
+	c.mu.Lock()
+	for b, cn := range c.conns {
+		cn.mu.Lock()
+		if cn.cli != nil {
+			cn.cli.Disconnect(disconnectQuiet)
+		}
+		cn.mu.Unlock()
+		delete(c.conns, b)
+	}
+	c.mu.Unlock()
+	return abandoned
+}
+
+// New returns an action for MQTT publish.
+func New(cli *Client, ac model.ActionConfig) (*MQTT, error) {
+	t, err := ParseTemplates(ac.MQTT)
+	if err != nil {
+		return nil, err
+	}
+	return &MQTT{
+		cli:       cli,
+		name:      ac.Name,
+		qos:       ac.MQTT.QoS,
+		retain:    ac.MQTT.Retain,
+		payload:   ac.Payload,
+		templates: t,
+	}, nil
+}
+
+// List lists schedule events from MQTT action scheduler.
+func (a *MQTT) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders messages of schedule events and registers them to MQTT action scheduler.
+func (a *MQTT) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	return render.Register(a.cli.scheduler, a.name, events, func(event model.ScheduleEvent) (interface{}, error) {
+		return a.newMessage(event)
+	})
+}
+
+func (a *MQTT) newMessage(event model.ScheduleEvent) (*message, error) {
+	data := render.NewData(a.name, event)
+	topic, err := render.Execute(a.templates.topic, data)
+	if err != nil {
+		return nil, err
+	}
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return nil, fmt.Errorf("invalid topic name: %q", topic)
+	}
+	payload, err := render.Body(a.templates.message, data, a.payload, event)
+	if err != nil {
+		return nil, err
+	}
+	return &message{
+		Topic:   topic,
+		QoS:     a.qos,
+		Retain:  a.retain,
+		Payload: payload,
+	}, nil
+}
+
+// Unregister unregisters schedule events from MQTT action scheduler.
+func (a *MQTT) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
@@ -0,0 +1,227 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+// testBroker is a minimal MQTT broker which records published messages.
+type testBroker struct {
+	ln        net.Listener
+	mu        sync.Mutex
+	clientIDs []string
+	passwords []string
+	published []*packets.PublishPacket
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *testBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		cp, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		var resp packets.ControlPacket
+		switch p := cp.(type) {
+		case *packets.ConnectPacket:
+			b.mu.Lock()
+			b.clientIDs = append(b.clientIDs, p.ClientIdentifier)
+			b.passwords = append(b.passwords, string(p.Password))
+			b.mu.Unlock()
+			resp = packets.NewControlPacket(packets.Connack)
+		case *packets.PublishPacket:
+			b.mu.Lock()
+			b.published = append(b.published, p)
+			b.mu.Unlock()
+			if p.Qos == 1 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				resp = ack
+			}
+		case *packets.PingreqPacket:
+			resp = packets.NewControlPacket(packets.Pingresp)
+		case *packets.DisconnectPacket:
+			return
+		}
+		if resp != nil {
+			if err := resp.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	b := newTestBroker(t)
+	c := &Client{clientID: "test", conns: make(map[broker]*connection)}
+	t.Cleanup(func() {
+		for _, cn := range c.conns {
+			cn.cli.Disconnect(0)
+		}
+	})
+
+	ac := model.ActionConfig{
+		Name: "light",
+		MQTT: model.MQTTAction{
+			Broker:   "tcp://" + b.ln.Addr().String(),
+			Username: "user",
+			Password: "secret",
+			Topic:    "home/{{.Summary}}/{{.EventType}}",
+			Message:  `{"state":"{{if eq .EventType "Start"}}ON{{else}}OFF{{end}}"}`,
+			QoS:      1,
+			Retain:   true,
+		},
+	}
+	c.configs = func(name model.ActionName) (model.ActionConfig, bool) {
+		return ac, name == ac.Name
+	}
+	a, err := New(c, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []model.ScheduleEvent{
+		{ScheduleID: "sid", Summary: "light", EventType: model.Start, ExecuteAt: time.Now()},
+		{ScheduleID: "sid", Summary: "light", EventType: model.End, ExecuteAt: time.Now()},
+	}
+	for _, event := range events {
+		m, err := a.newMessage(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(d), "secret") {
+			t.Fatalf("password should not be stored in payload: %s", d)
+		}
+		if err := c.execute(context.Background(), &scheduler.Task{ActionName: a.name, Event: event, Payload: d}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.clientIDs) != 1 || !strings.HasPrefix(b.clientIDs[0], "test-") {
+		t.Errorf("connection should be reused: %v", b.clientIDs)
+	}
+	if len(b.passwords) != 1 || b.passwords[0] != "secret" {
+		t.Errorf("password should be looked up from action config: %v", b.passwords)
+	}
+	want := []struct{ topic, payload string }{
+		{"home/light/Start", `{"state":"ON"}`},
+		{"home/light/End", `{"state":"OFF"}`},
+	}
+	if len(b.published) != len(want) {
+		t.Fatalf("want %d messages but got %d", len(want), len(b.published))
+	}
+	for i, p := range b.published {
+		if p.TopicName != want[i].topic || string(p.Payload) != want[i].payload {
+			t.Errorf("want %v but got %s %s", want[i], p.TopicName, p.Payload)
+		}
+		if p.Qos != 1 || !p.Retain {
+			t.Errorf("unexpected qos and retain: %d %v", p.Qos, p.Retain)
+		}
+	}
+}
+
+func TestMQTT_newMessage(t *testing.T) {
+	t.Parallel()
+	event := model.ScheduleEvent{ScheduleID: "sid", Summary: "light", EventType: model.Start}
+	tests := []struct {
+		name      string
+		action    model.MQTTAction
+		wantTopic string
+		wantErr   bool
+	}{
+		{
+			name:      "topic is rendered",
+			action:    model.MQTTAction{Topic: "home/{{.Summary}}", QoS: 1},
+			wantTopic: "home/light",
+		},
+		{
+			name:    "topic should not contain wildcards",
+			action:  model.MQTTAction{Topic: "home/+/{{.Summary}}"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := New(&Client{}, model.ActionConfig{Name: "light", MQTT: tt.action})
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := a.newMessage(event)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err should not be nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Topic != tt.wantTopic || m.QoS != tt.action.QoS {
+				t.Errorf("want %s %d but got %s %d", tt.wantTopic, tt.action.QoS, m.Topic, m.QoS)
+			}
+		})
+	}
+}
+
+func TestClient_connClientID(t *testing.T) {
+	t.Parallel()
+	c := &Client{clientID: "test"}
+	user := broker{URL: "tcp://localhost:1883", Username: "user"}
+	admin := broker{URL: "tcp://localhost:1883", Username: "admin"}
+	ids := make(map[string]bool)
+	for _, b := range []broker{user, user, admin} {
+		id, err := c.connClientID(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[id] = true
+	}
+	if len(ids) != 2 {
+		t.Errorf("client ID should be unique to the connection: %v", ids)
+	}
+	id, err := c.connClientID(broker{URL: "tcp://localhost:1883", ClientID: "light"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "light" {
+		t.Errorf("configured client ID should be used: %s", id)
+	}
+}
@@ -0,0 +1,248 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+	"github.com/ww24/calendar-notifier/internal/tlsconfig"
+)
+
+const (
+	timeout   = 15 * time.Second
+	namespace = "nats"
+	// msgIDHeader is header for deduplication of JetStream.
+	msgIDHeader = "Nats-Msg-Id"
+)
+
+// NATS implements repository.Action for NATS publish.
+type NATS struct {
+	cli       *Client
+	name      model.ActionName
+	jetStream bool
+	payload   map[string]interface{}
+	templates *Templates
+}
+
+// Client represents NATS client which pools connections to servers.
+type Client struct {
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+	conns     map[server]*nats.Conn
+	mu        sync.Mutex
+}
+
+// server identifies a connection to NATS servers.
+type server struct {
+	URL       string          `json:"url"`
+	CredsFile string          `json:"creds_file,omitempty"`
+	TLS       model.TLSConfig `json:"tls"`
+}
+
+// message is NATS message which is stored in scheduler.
+type message struct {
+	Subject   string `json:"subject"`
+	MsgID     string `json:"msg_id"`
+	JetStream bool   `json:"jetstream"`
+	Data      []byte `json:"data"`
+}
+
+// Templates is subject and message templates of NATS action.
+type Templates struct {
+	subject *template.Template
+	message *template.Template
+}
+
+// ParseTemplates parses subject and message templates of NATS action.
+func ParseTemplates(na model.NATSAction) (*Templates, error) {
+	t := &Templates{}
+	var err error
+	if t.subject, err = render.Parse("subject", na.Subject); err != nil {
+		return nil, err
+	}
+	if t.message, err = render.Parse("message", na.Message); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewClient returns NATS client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{
+		configs: configs,
+		conns:   make(map[server]*nats.Conn),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+// conn returns connection to the servers, connecting it on first use.
+// Connection reconnects automatically once it has been established.
+func (c *Client) conn(s server) (*nats.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if nc, ok := c.conns[s]; ok && !nc.IsClosed() {
+		return nc, nil
+	}
+	opts := []nats.Option{
+		nats.Name("calendar-notifier"),
+		nats.Timeout(timeout),
+		nats.MaxReconnects(-1),
+	}
+	if s.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(s.CredsFile))
+	}
+	if s.TLS != (model.TLSConfig{}) {
+		cnf, err := tlsconfig.New(s.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.Secure(cnf))
+	}
+	nc, err := nats.Connect(s.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", s.URL, err)
+	}
+	log.Println("[nats action] connected, server:", nc.ConnectedUrlRedacted())
+	c.conns[s] = nc
+	return nc, nil
+}
+
+func newServer(na model.NATSAction) server {
+	return server{
+		URL:       na.URL,
+		CredsFile: na.CredsFile,
+		TLS:       na.TLS,
+	}
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	nc, err := c.conn(newServer(ac.NATS))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	msg := nats.NewMsg(m.Subject)
+	msg.Header.Set(msgIDHeader, m.MsgID)
+	msg.Data = m.Data
+	if !m.JetStream {
+		if err := nc.PublishMsg(msg); err != nil {
+			return err
+		}
+		// make sure that the message reaches the server
+		if err := nc.FlushWithContext(ctx); err != nil {
+			return err
+		}
+		log.Println("[nats action] published, subject:", m.Subject)
+		scheduler.SetResult(ctx, scheduler.Result{MessageID: m.MsgID})
+		return nil
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return err
+	}
+	ack, err := js.PublishMsg(msg, nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+	log.Printf("[nats action] published, stream: %s, seq: %d, duplicate: %v\n", ack.Stream, ack.Sequence, ack.Duplicate)
+	scheduler.SetResult(ctx, scheduler.Result{MessageID: fmt.Sprintf("%s:%d", ack.Stream, ack.Sequence)})
+	return nil
+}
+
+// Shutdown shuts down NATS action scheduler and returns abandoned events.
+// Connections are drained after scheduled events are published.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	abandoned := c.scheduler.Shutdown(ctx, grace)
+	c.mu.Lock()
+	for s, nc := range c.conns {
+		if err := nc.Drain(); err != nil {
+			log.Println("[nats action] drain error:", err)
+			nc.Close()
+		}
+		delete(c.conns, s)
+	}
+	c.mu.Unlock()
+	return abandoned
+}
+
+// New returns an action for NATS publish.
+func New(cli *Client, ac model.ActionConfig) (*NATS, error) {
+	t, err := ParseTemplates(ac.NATS)
+	if err != nil {
+		return nil, err
+	}
+	return &NATS{
+		cli:       cli,
+		name:      ac.Name,
+		jetStream: ac.NATS.JetStream,
+		payload:   ac.Payload,
+		templates: t,
+	}, nil
+}
+
+// List lists schedule events from NATS action scheduler.
+func (a *NATS) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders messages of schedule events and registers them to NATS action scheduler.
+func (a *NATS) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	return render.Register(a.cli.scheduler, a.name, events, func(event model.ScheduleEvent) (interface{}, error) {
+		return a.newMessage(event)
+	})
+}
+
+func (a *NATS) newMessage(event model.ScheduleEvent) (*message, error) {
+	data := render.NewData(a.name, event)
+	subject, err := render.Execute(a.templates.subject, data)
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" || strings.ContainsAny(subject, "*> \t\r\n") {
+		return nil, fmt.Errorf("invalid subject: %q", subject)
+	}
+	payload, err := render.Body(a.templates.message, data, a.payload, event)
+	if err != nil {
+		return nil, err
+	}
+	return &message{
+		Subject: subject,
+		// message ID is unique to the action so that actions publishing to the same stream do not conflict
+		MsgID:     string(a.name) + ":" + event.ID(""),
+		JetStream: a.jetStream,
+		Data:      payload,
+	}, nil
+}
+
+// Unregister unregisters schedule events from NATS action scheduler.
+func (a *NATS) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
@@ -0,0 +1,282 @@
+package nats
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+func runServer(t *testing.T) *natsserver.Server {
+	t.Helper()
+	s, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	c := &Client{conns: make(map[server]*nats.Conn)}
+	t.Cleanup(func() {
+		for _, nc := range c.conns {
+			nc.Close()
+		}
+	})
+	return c
+}
+
+// newTestAction returns NATS action whose client looks up the action config.
+func newTestAction(t *testing.T, ac model.ActionConfig) *NATS {
+	t.Helper()
+	c := newTestClient(t)
+	c.configs = func(name model.ActionName) (model.ActionConfig, bool) {
+		return ac, name == ac.Name
+	}
+	a, err := New(c, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func execute(t *testing.T, a *NATS, event model.ScheduleEvent) scheduler.Result {
+	t.Helper()
+	m, err := a.newMessage(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ac, _ := a.cli.configs(a.name); strings.Contains(string(d), ac.NATS.URL) {
+		t.Errorf("server should not be stored in payload: %s", d)
+	}
+	ctx, res := scheduler.WithResult(context.Background())
+	if err := a.cli.execute(ctx, &scheduler.Task{ActionName: a.name, Event: event, Payload: d}); err != nil {
+		t.Fatal(err)
+	}
+	return *res
+}
+
+var testEvent = model.ScheduleEvent{
+	ScheduleID: "sid",
+	Summary:    "light",
+	EventType:  model.Start,
+	ExecuteAt:  time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC),
+}
+
+func TestClient_execute(t *testing.T) {
+	t.Parallel()
+	s := runServer(t)
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	sub, err := nc.SubscribeSync("home.>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAction(t, model.ActionConfig{
+		Name: "light_on",
+		NATS: model.NATSAction{
+			URL:     s.ClientURL(),
+			Subject: "home.{{.Summary}}.{{.EventType}}",
+		},
+		Payload: map[string]interface{}{"state": "ON"},
+	})
+	execute(t, a, testEvent)
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "home.light.Start" {
+		t.Errorf("unexpected subject: %s", msg.Subject)
+	}
+	if string(msg.Data) != `{"state":"ON"}` {
+		t.Errorf("unexpected data: %s", msg.Data)
+	}
+	if got := msg.Header.Get(msgIDHeader); got != "light_on:"+testEvent.ID("") {
+		t.Errorf("unexpected message id: %s", got)
+	}
+}
+
+func TestClient_execute_jetStream(t *testing.T) {
+	t.Parallel()
+	s := runServer(t)
+	nc, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "HOME", Subjects: []string{"home.>"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAction(t, model.ActionConfig{
+		Name: "light_on",
+		NATS: model.NATSAction{
+			URL:       s.ClientURL(),
+			Subject:   "home.{{.Summary}}",
+			JetStream: true,
+		},
+	})
+	// publishing the same event again is deduplicated by the stream
+	for i := 0; i < 2; i++ {
+		res := execute(t, a, testEvent)
+		if res.MessageID != "HOME:1" {
+			t.Errorf("unexpected message id: %s", res.MessageID)
+		}
+	}
+	info, err := js.StreamInfo("HOME")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State.Msgs != 1 {
+		t.Errorf("want 1 message but got %d", info.State.Msgs)
+	}
+}
+
+// writeCert writes self-signed certificate for 127.0.0.1 which is used by both server and client.
+func writeCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "calendar-notifier"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestClient_conn_tls(t *testing.T) {
+	t.Parallel()
+	certFile, keyFile := writeCert(t)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	// server requires client certificate
+	s, err := natsserver.NewServer(&natsserver.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		NoLog:     true,
+		NoSigs:    true,
+		TLS:       true,
+		TLSVerify: true,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			MinVersion:   tls.VersionTLS12,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	t.Cleanup(s.Shutdown)
+
+	tests := []struct {
+		name string
+		tls  model.TLSConfig
+	}{
+		{
+			name: "server is verified by CA certificate",
+			tls:  model.TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile},
+		},
+		{
+			name: "client certificate is kept without verification",
+			tls:  model.TLSConfig{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			nc, err := newTestClient(t).conn(server{URL: s.ClientURL(), TLS: tt.tls})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !nc.IsConnected() {
+				t.Fatal("client should be connected")
+			}
+		})
+	}
+}
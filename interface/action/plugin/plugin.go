@@ -0,0 +1,341 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+const (
+	// ProtocolVersion is version of the plugin protocol sent on configure.
+	ProtocolVersion = 1
+	defaultTimeout  = 30 * time.Second
+	// closeTimeout is time to wait for plugins to exit after stdin is closed.
+	closeTimeout = 5 * time.Second
+)
+
+// methods of the plugin protocol.
+const (
+	methodConfigure  = "configure"
+	methodList       = "list"
+	methodRegister   = "register"
+	methodUnregister = "unregister"
+)
+
+// Plugin implements repository.Action for external plugin.
+// Plugin process is started on first call and restarted on next call after it fails.
+type Plugin struct {
+	name    model.ActionName
+	cnf     model.PluginAction
+	timeout time.Duration
+	proc    *process
+	nextID  uint64
+	mu      sync.Mutex
+}
+
+// Client manages plugin processes.
+type Client struct {
+	plugins map[model.ActionName]*Plugin
+	mu      sync.Mutex
+}
+
+// process is running plugin process.
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+	dec   *json.Decoder
+}
+
+// request is request message sent to stdin of the plugin.
+type request struct {
+	ID     uint64      `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+// response is response message read from stdout of the plugin.
+type response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Error is error returned by the plugin.
+type Error struct {
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return "plugin error: " + e.Message
+}
+
+type configureParams struct {
+	ProtocolVersion int                    `json:"protocol_version"`
+	ActionName      model.ActionName       `json:"action_name"`
+	Options         map[string]interface{} `json:"options"`
+}
+
+type eventsParams struct {
+	Events []event `json:"events"`
+}
+
+// event is schedule event in the plugin protocol.
+type event struct {
+	EventID     string          `json:"event_id"`
+	ScheduleID  string          `json:"schedule_id"`
+	Summary     string          `json:"summary"`
+	Description string          `json:"description"`
+	Attendees   []string        `json:"attendees"`
+	EventType   model.EventType `json:"event_type"`
+	ExecuteAt   time.Time       `json:"execute_at"`
+}
+
+func newEvents(events []model.ScheduleEvent) []event {
+	es := make([]event, 0, len(events))
+	for _, e := range events {
+		es = append(es, event{
+			EventID:     e.ID(""),
+			ScheduleID:  e.ScheduleID,
+			Summary:     e.Summary,
+			Description: e.Description,
+			Attendees:   e.Attendees,
+			EventType:   e.EventType,
+			ExecuteAt:   e.ExecuteAt,
+		})
+	}
+	return es
+}
+
+// NewClient returns plugin client.
+func NewClient() *Client {
+	return &Client{
+		plugins: make(map[model.ActionName]*Plugin),
+	}
+}
+
+// Close closes stdin of plugin processes, and kills them if they do not exit in time.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for name, p := range c.plugins {
+		if e := p.stop(closeTimeout); e != nil && err == nil {
+			err = fmt.Errorf("%s: %w", p.name, e)
+		}
+		delete(c.plugins, name)
+	}
+	return err
+}
+
+// New returns an action for external plugin.
+// Plugin is reused for the same action name and parameters so that a plugin process runs per action,
+// and the process of the previous plugin is stopped if the parameters have been changed.
+func New(cli *Client, ac model.ActionConfig) (*Plugin, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if p, ok := cli.plugins[ac.Name]; ok {
+		if reflect.DeepEqual(p.cnf, ac.Plugin) {
+			return p, nil
+		}
+		if err := p.stop(closeTimeout); err != nil {
+			log.Printf("[plugin action] %s: stopped: %v\n", p.name, err)
+		}
+	}
+	timeout := ac.Plugin.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	p := &Plugin{
+		name:    ac.Name,
+		cnf:     ac.Plugin,
+		timeout: timeout,
+	}
+	cli.plugins[ac.Name] = p
+	return p, nil
+}
+
+// List lists schedule events registered to the plugin.
+func (p *Plugin) List(ctx context.Context) (model.ScheduleEvents, error) {
+	res := &eventsParams{}
+	if err := p.call(ctx, methodList, struct{}{}, res); err != nil {
+		return nil, err
+	}
+	events := make(model.ScheduleEvents, 0, len(res.Events))
+	for _, e := range res.Events {
+		events = append(events, model.ScheduleEvent{
+			ScheduleID:  e.ScheduleID,
+			Summary:     e.Summary,
+			Description: e.Description,
+			Attendees:   e.Attendees,
+			EventType:   e.EventType,
+			ExecuteAt:   e.ExecuteAt,
+		})
+	}
+	return events, nil
+}
+
+// Register registers schedule events to the plugin.
+func (p *Plugin) Register(ctx context.Context, events ...model.ScheduleEvent) error {
+	return p.call(ctx, methodRegister, eventsParams{Events: newEvents(events)}, nil)
+}
+
+// Unregister unregisters schedule events from the plugin.
+func (p *Plugin) Unregister(ctx context.Context, events ...model.ScheduleEvent) error {
+	return p.call(ctx, methodUnregister, eventsParams{Events: newEvents(events)}, nil)
+}
+
+// call calls method of the plugin, and decodes result into result if it is not nil.
+// Plugin process is stopped if it does not respond properly.
+func (p *Plugin) call(ctx context.Context, method string, params, result interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc == nil {
+		if err := p.start(ctx); err != nil {
+			return err
+		}
+	}
+	res, err := p.roundTrip(ctx, method, params)
+	if err != nil {
+		if err := p.proc.stop(0); err != nil {
+			log.Printf("[plugin action] %s: stopped: %v\n", p.name, err)
+		}
+		p.proc = nil
+		return err
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(res.Result, result); err != nil {
+			return fmt.Errorf("%s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// start starts plugin process and configures it.
+func (p *Plugin) start(ctx context.Context) error {
+	cmd := exec.Command(p.cnf.Path, p.cnf.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start plugin %s: %w", p.name, err)
+	}
+	go func() {
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			log.Printf("[plugin action] %s: %s\n", p.name, s.Text())
+		}
+	}()
+	p.proc = &process{
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   json.NewEncoder(stdin),
+		dec:   json.NewDecoder(stdout),
+	}
+
+	res, err := p.roundTrip(ctx, methodConfigure, configureParams{
+		ProtocolVersion: ProtocolVersion,
+		ActionName:      p.name,
+		Options:         p.cnf.Options,
+	})
+	if err == nil && res.Error != nil {
+		err = res.Error
+	}
+	if err != nil {
+		if err := p.proc.stop(0); err != nil {
+			log.Printf("[plugin action] %s: stopped: %v\n", p.name, err)
+		}
+		p.proc = nil
+		return fmt.Errorf("configure plugin %s: %w", p.name, err)
+	}
+	log.Printf("[plugin action] %s: started, pid: %d\n", p.name, cmd.Process.Pid)
+	return nil
+}
+
+// stop stops plugin process if it is running.
+func (p *Plugin) stop(timeout time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc == nil {
+		return nil
+	}
+	err := p.proc.stop(timeout)
+	p.proc = nil
+	return err
+}
+
+// roundTrip sends request to the plugin and reads its response.
+func (p *Plugin) roundTrip(ctx context.Context, method string, params interface{}) (*response, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	p.nextID++
+	id := p.nextID
+	if err := p.proc.enc.Encode(request{ID: id, Method: method, Params: params}); err != nil {
+		return nil, fmt.Errorf("send %s: %w", method, err)
+	}
+
+	type result struct {
+		res *response
+		err error
+	}
+	// decoder is not interrupted by the context, the goroutine ends when the process is stopped.
+	ch := make(chan result, 1)
+	dec := p.proc.dec
+	go func() {
+		res := &response{}
+		err := dec.Decode(res)
+		ch <- result{res: res, err: err}
+	}()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, fmt.Errorf("receive %s: %w", method, r.err)
+		}
+		if r.res.ID != id {
+			return nil, fmt.Errorf("receive %s: unexpected response id: %d", method, r.res.ID)
+		}
+		return r.res, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// stop closes stdin of the process and waits for it to exit.
+// The process is killed if it does not exit within timeout.
+func (pr *process) stop(timeout time.Duration) error {
+	pr.stdin.Close()
+	errc := make(chan error, 1)
+	go func() { errc <- pr.cmd.Wait() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errc:
+		return err
+	case <-timer.C:
+		if err := pr.cmd.Process.Kill(); err != nil {
+			log.Println("[plugin action] kill error:", err)
+		}
+		return <-errc
+	}
+}
@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// TestHelperPlugin is not a test but a plugin which stores events in memory.
+// It is executed as a plugin process by tests.
+func TestHelperPlugin(t *testing.T) {
+	if args := flag.Args(); len(args) == 0 || args[0] != "plugin" {
+		t.Skip("helper process for tests")
+	}
+	var options map[string]interface{}
+	events := make(map[string]json.RawMessage)
+	dec := json.NewDecoder(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	for {
+		var req struct {
+			ID     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := dec.Decode(&req); err != nil {
+			os.Exit(0)
+		}
+		var params struct {
+			ProtocolVersion int                    `json:"protocol_version"`
+			Options         map[string]interface{} `json:"options"`
+			Events          []json.RawMessage      `json:"events"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			os.Exit(1)
+		}
+		res := map[string]interface{}{"id": req.ID}
+		switch req.Method {
+		case "configure":
+			options = params.Options
+			fmt.Fprintln(os.Stderr, "configured, protocol version:", params.ProtocolVersion)
+			res["result"] = struct{}{}
+		case "list":
+			list := make([]json.RawMessage, 0, len(events))
+			for _, e := range events {
+				list = append(list, e)
+			}
+			res["result"] = map[string]interface{}{"events": list}
+		case "register", "unregister":
+			if options["fail"] == true {
+				res["error"] = map[string]string{"message": "failed to " + req.Method}
+				break
+			}
+			if options["hang"] == true {
+				time.Sleep(time.Minute)
+			}
+			for _, e := range params.Events {
+				var id struct {
+					EventID string `json:"event_id"`
+				}
+				if err := json.Unmarshal(e, &id); err != nil {
+					os.Exit(1)
+				}
+				if req.Method == "register" {
+					events[id.EventID] = e
+				} else {
+					delete(events, id.EventID)
+				}
+			}
+			res["result"] = struct{}{}
+		default:
+			res["error"] = map[string]string{"message": "unknown method: " + req.Method}
+		}
+		if err := enc.Encode(res); err != nil {
+			os.Exit(1)
+		}
+	}
+}
+
+func newTestPlugin(t *testing.T, options map[string]interface{}, timeout time.Duration) *Plugin {
+	t.Helper()
+	cli := NewClient()
+	t.Cleanup(func() {
+		if err := cli.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	p, err := New(cli, model.ActionConfig{
+		Name: "light",
+		Plugin: model.PluginAction{
+			Path:    os.Args[0],
+			Args:    []string{"-test.run=^TestHelperPlugin$", "--", "plugin"},
+			Options: options,
+			Timeout: timeout,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPlugin(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	p := newTestPlugin(t, nil, 0)
+	executeAt := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	start := model.ScheduleEvent{ScheduleID: "sid", Summary: "light", Attendees: []string{"a@example.com"}, EventType: model.Start, ExecuteAt: executeAt}
+	end := model.ScheduleEvent{ScheduleID: "sid", Summary: "light", EventType: model.End, ExecuteAt: executeAt.Add(time.Hour)}
+
+	if err := p.Register(ctx, start, end); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unregister(ctx, end); err != nil {
+		t.Fatal(err)
+	}
+	events, err := p.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("want 1 event but got %d", len(events))
+	}
+	got := events[0]
+	if got.ID("") != start.ID("") || got.EventType != model.Start || len(got.Attendees) != 1 {
+		t.Errorf("want %+v but got %+v", start, got)
+	}
+}
+
+func TestPlugin_error(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	event := model.ScheduleEvent{ScheduleID: "sid", EventType: model.Start, ExecuteAt: time.Now()}
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		timeout time.Duration
+		wantErr func(error) bool
+	}{
+		{
+			name:    "error response of the plugin",
+			options: map[string]interface{}{"fail": true},
+			wantErr: func(err error) bool {
+				var pe *Error
+				return errors.As(err, &pe) && pe.Message == "failed to register"
+			},
+		},
+		{
+			name:    "plugin does not respond in time",
+			options: map[string]interface{}{"hang": true},
+			timeout: 100 * time.Millisecond,
+			wantErr: func(err error) bool {
+				return errors.Is(err, context.DeadlineExceeded)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := newTestPlugin(t, tt.options, tt.timeout)
+			if err := p.Register(ctx, event); !tt.wantErr(err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// plugin is available after the error, restarting it if needed
+			if _, err := p.List(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestNew_reuse(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cli := NewClient()
+	t.Cleanup(func() {
+		if err := cli.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	ac := model.ActionConfig{
+		Name: "light",
+		Plugin: model.PluginAction{
+			Path:    os.Args[0],
+			Args:    []string{"-test.run=^TestHelperPlugin$", "--", "plugin"},
+			Options: map[string]interface{}{"room": "living"},
+		},
+	}
+	// action is configured on every sync
+	var pids []int
+	for i := 0; i < 3; i++ {
+		p, err := New(cli, ac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.List(ctx); err != nil {
+			t.Fatal(err)
+		}
+		pids = append(pids, p.proc.cmd.Process.Pid)
+	}
+	if len(cli.plugins) != 1 {
+		t.Errorf("want 1 plugin but got %d", len(cli.plugins))
+	}
+	if pids[0] != pids[1] || pids[1] != pids[2] {
+		t.Errorf("plugin process should be reused: %v", pids)
+	}
+
+	// plugin is replaced when the parameters are changed
+	old := cli.plugins["light"]
+	ac.Plugin.Options = map[string]interface{}{"room": "bedroom"}
+	p, err := New(cli, ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == old || old.proc != nil {
+		t.Error("previous plugin process should be stopped")
+	}
+}
@@ -4,24 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/pubsub"
 	"golang.org/x/oauth2/google"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
 	"github.com/ww24/calendar-notifier/internal/scheduler"
 )
 
 const (
-	timeout = 15 * time.Second
+	timeout   = 15 * time.Second
+	namespace = "pubsub"
 )
 
 // PubSub implements repository.Action for pubsub.
 type PubSub struct {
 	cli     *Client
 	name    model.ActionName
-	topic   *pubsub.Topic
+	topic   string
 	payload map[string]interface{}
 }
 
@@ -29,10 +32,18 @@ type PubSub struct {
 type Client struct {
 	cli       *pubsub.Client
 	scheduler scheduler.Scheduler
+	topics    map[string]*pubsub.Topic
+	mu        sync.Mutex
+}
+
+// message is serialized pubsub message which is stored in scheduler.
+type message struct {
+	Topic string `json:"topic"`
+	Data  []byte `json:"data"`
 }
 
 // NewClient returns cloud pubsub client.
-func NewClient(ctx context.Context) (*Client, error) {
+func NewClient(ctx context.Context, newScheduler scheduler.Factory) (*Client, error) {
 	cred, err := google.FindDefaultCredentials(ctx)
 	if err != nil {
 		return nil, err
@@ -42,20 +53,64 @@ func NewClient(ctx context.Context) (*Client, error) {
 		return nil, err
 	}
 	c := &Client{
-		cli:       cli,
-		scheduler: scheduler.NewInMemory(ctx),
+		cli:    cli,
+		topics: make(map[string]*pubsub.Topic),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
 	}
+	c.scheduler = s
 	return c, nil
 }
 
+func (c *Client) topic(id string) *pubsub.Topic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if topic, ok := c.topics[id]; ok {
+		return topic
+	}
+	topic := c.cli.Topic(id)
+	topic.PublishSettings.Timeout = timeout
+	c.topics[id] = topic
+	return topic
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	pr := c.topic(m.Topic).Publish(ctx, &pubsub.Message{Data: m.Data})
+	id, err := pr.Get(ctx)
+	if err != nil {
+		return err
+	}
+	log.Println("[pubsub action] published, server_id:", id)
+	scheduler.SetResult(ctx, scheduler.Result{MessageID: id})
+	return nil
+}
+
+// Shutdown shuts down cloud pubsub action scheduler and returns abandoned events.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	abandoned := c.scheduler.Shutdown(ctx, grace)
+	c.mu.Lock()
+	for _, topic := range c.topics {
+		topic.Stop()
+	}
+	c.mu.Unlock()
+	if err := c.cli.Close(); err != nil {
+		log.Println("[pubsub action] close error:", err)
+	}
+	return abandoned
+}
+
 // New returns an action for cloud pubsub.
 func New(cli *Client, ac model.ActionConfig) *PubSub {
-	topic := cli.cli.Topic(ac.Topic)
-	topic.PublishSettings.Timeout = timeout
 	return &PubSub{
 		cli:     cli,
 		name:    ac.Name,
-		topic:   topic,
+		topic:   ac.Topic,
 		payload: ac.Payload,
 	}
 }
@@ -78,18 +133,13 @@ func (a *PubSub) Register(_ context.Context, events ...model.ScheduleEvent) erro
 		if err != nil {
 			return err
 		}
-		err = a.cli.scheduler.Register(a.name, event, func(ctx context.Context) error {
-			pr := a.topic.Publish(ctx, &pubsub.Message{Data: d})
-			id, err := pr.Get(ctx)
-			if err != nil {
-				return err
-			}
-			log.Println("[pubsub action] published, server_id:", id)
-			return nil
-		})
+		m, err := json.Marshal(&message{Topic: a.topic, Data: d})
 		if err != nil {
 			return err
 		}
+		if err := a.cli.scheduler.Register(a.name, event, m); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+// Factory creates actions of an action type registered by library users.
+type Factory interface {
+	// NewParams returns pointer to parameter struct which the config block of the action is decoded into.
+	// The config block is the block named after the action type, e.g. `light:` block of `type: light`.
+	NewParams() interface{}
+	// Validate validates decoded parameters on config validation.
+	Validate(params interface{}) error
+	// New returns action from action config, and Params of the action config is decoded parameters.
+	New(ctx context.Context, ac model.ActionConfig) (repository.Action, error)
+}
+
+// Shutdowner is implemented optionally by actions of registered action types.
+// Action is created once per action name and reused on every sync, and Shutdown is called on graceful shutdown.
+type Shutdowner interface {
+	// Shutdown waits for events scheduled within grace window until ctx is done,
+	// and returns schedule events which are abandoned.
+	Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent
+}
+
+var (
+	factories = make(map[model.ActionType]Factory)
+	mu        sync.RWMutex
+)
+
+// builtin is action types which are implemented by this project.
+var builtin = map[model.ActionType]struct{}{
+	model.ActionHTTP:   {},
+	model.ActionPubSub: {},
+	model.ActionTasks:  {},
+	model.ActionSlack:  {},
+	model.ActionEmail:  {},
+	model.ActionExec:   {},
+	model.ActionMQTT:   {},
+	model.ActionNATS:   {},
+	model.ActionAMQP:   {},
+	model.ActionKafka:  {},
+	model.ActionGRPC:   {},
+	model.ActionPlugin: {},
+}
+
+// Register registers factory of action type.
+// It should be called before config is parsed, and the action type should not be built-in or registered.
+func Register(t model.ActionType, f Factory) error {
+	if t == "" {
+		return errors.New("action type is required")
+	}
+	if f == nil {
+		return errors.New("factory is nil")
+	}
+	if _, ok := builtin[t]; ok {
+		return fmt.Errorf("built-in action type: %s", t)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := factories[t]; ok {
+		return fmt.Errorf("action type is already registered: %s", t)
+	}
+	factories[t] = f
+	return nil
+}
+
+// Lookup returns factory of registered action type.
+func Lookup(t model.ActionType) (Factory, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := factories[t]
+	return f, ok
+}
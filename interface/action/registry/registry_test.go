@@ -0,0 +1,54 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+type factory struct{}
+
+func (factory) NewParams() interface{}     { return &struct{}{} }
+func (factory) Validate(interface{}) error { return nil }
+func (factory) New(context.Context, model.ActionConfig) (repository.Action, error) {
+	return nil, nil
+}
+
+var registerOnce sync.Once
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+	registerOnce.Do(func() {
+		if err := Register("registered", factory{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	tests := []struct {
+		name       string
+		actionType model.ActionType
+		factory    Factory
+	}{
+		{name: "action type is required", actionType: "", factory: factory{}},
+		{name: "factory is required", actionType: "light", factory: nil},
+		{name: "built-in action type", actionType: model.ActionHTTP, factory: factory{}},
+		{name: "registered action type", actionType: "registered", factory: factory{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := Register(tt.actionType, tt.factory); err == nil {
+				t.Fatal("err should not be nil")
+			}
+		})
+	}
+	if _, ok := Lookup("registered"); !ok {
+		t.Error("registered action type should be found")
+	}
+	if _, ok := Lookup(model.ActionHTTP); ok {
+		t.Error("built-in action type should not be found")
+	}
+}
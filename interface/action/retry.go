@@ -0,0 +1,134 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/http"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/internal/backoff"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const retryBackoffJitter = 0.2
+
+// withRetry wraps scheduler handler with retry policy of each action.
+// Events which failed in the end are sent to dead-letter sink of the action.
+func (a *Action) withRetry(handler scheduler.Handler) scheduler.Handler {
+	return func(ctx context.Context, task *scheduler.Task) error {
+		ac, _ := a.config(task.ActionName)
+		if _, ok := deadLetterTarget(task.ActionName); ok {
+			// dead letter is executed once, and it is not listed by the dead-letter action
+			defer a.unregisterDeadLetter(ctx, ac, task)
+		}
+		startedAt := time.Now()
+		rctx, res := scheduler.WithResult(ctx)
+		attempts, err := retry(rctx, ac.Retry, task, handler)
+		a.record(ac, task.Event, startedAt, attempts, *res, err)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			// shutting down
+			return err
+		}
+		dl := model.DeadLetter{
+			ActionName: task.ActionName,
+			Event:      task.Event,
+			Attempts:   attempts,
+			Error:      err.Error(),
+			FailedAt:   time.Now(),
+		}
+		if derr := a.sendDeadLetter(ctx, ac.DeadLetter, dl); derr != nil {
+			log.Println("[dead letter] send error:", derr)
+		}
+		return err
+	}
+}
+
+// record records execution to history.
+func (a *Action) record(ac model.ActionConfig, event model.ScheduleEvent, startedAt time.Time, attempts int, res scheduler.Result, err error) {
+	if a.history == nil {
+		return
+	}
+	e := model.Execution{
+		ActionName:    ac.Name,
+		ActionType:    ac.Type,
+		EventID:       event.ID(""),
+		EventType:     event.EventType,
+		ScheduledAt:   event.ExecuteAt,
+		StartedAt:     startedAt,
+		Attempts:      attempts,
+		Outcome:       model.ExecutionSucceeded,
+		StatusCode:    res.StatusCode,
+		MessageID:     res.MessageID,
+		ExitCode:      res.ExitCode,
+		Stdout:        res.Stdout,
+		Stderr:        res.Stderr,
+		LatencyMillis: time.Since(startedAt).Milliseconds(),
+	}
+	if err != nil {
+		e.Outcome = model.ExecutionFailed
+		e.Error = err.Error()
+		var se *http.StatusError
+		if errors.As(err, &se) {
+			e.StatusCode = se.StatusCode
+		}
+	}
+	if err := a.history.Record(e); err != nil {
+		log.Println("[history] record error:", err)
+	}
+}
+
+// retry executes handler until it succeeds, attempts reach the limit or the deadline passes.
+// It returns the number of attempts.
+func retry(ctx context.Context, p model.RetryPolicy, task *scheduler.Task, handler scheduler.Handler) (int, error) {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, task.Event.ExecuteAt.Add(p.Deadline))
+		defer cancel()
+	}
+	b := backoff.Exponential{
+		InitialInterval: p.Backoff.InitialInterval,
+		MaxInterval:     p.Backoff.MaxInterval,
+		Multiplier:      p.Backoff.Multiplier,
+		Jitter:          retryBackoffJitter,
+	}
+	for attempt := 1; ; attempt++ {
+		err := handler(ctx, task)
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= p.MaxAttempts || !retryable(p, err) || ctx.Err() != nil {
+			return attempt, err
+		}
+
+		wait := b.Duration(attempt)
+		log.Printf("[retry] action=%s, schedule_id=%s, attempt=%d, retry after %s: %v\n",
+			task.ActionName, task.Event.ScheduleID, attempt, wait, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether err is retryable.
+// Timeouts of each request are retryable, and the deadline of retry policy and shutdown are checked by retry.
+// Permanent errors, e.g. broken payloads and rejected requests, are not retryable.
+func retryable(p model.RetryPolicy, err error) bool {
+	if permanent.Is(err) {
+		return false
+	}
+	var se *http.StatusError
+	if errors.As(err, &se) {
+		return p.Retryable(se.StatusCode)
+	}
+	return true
+}
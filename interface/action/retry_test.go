@@ -0,0 +1,278 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/http"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/history"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	errNetwork := errors.New("network error")
+	backoff := model.Backoff{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}
+	tests := []struct {
+		name         string
+		policy       model.RetryPolicy
+		executeAt    time.Duration
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "no retry",
+			policy:       model.RetryPolicy{},
+			errs:         []error{errNetwork},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "succeed after retry",
+			policy:       model.RetryPolicy{MaxAttempts: 3, Backoff: backoff},
+			errs:         []error{errNetwork, &http.StatusError{StatusCode: 503}},
+			wantAttempts: 3,
+			wantErr:      false,
+		},
+		{
+			name:         "give up after max attempts",
+			policy:       model.RetryPolicy{MaxAttempts: 2, Backoff: backoff},
+			errs:         []error{errNetwork, errNetwork, errNetwork},
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "status code is not retryable",
+			policy:       model.RetryPolicy{MaxAttempts: 3, Backoff: backoff},
+			errs:         []error{&http.StatusError{StatusCode: 400}},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "configured status code is retryable",
+			policy:       model.RetryPolicy{MaxAttempts: 3, Backoff: backoff, RetryableStatusCodes: []int{404}},
+			errs:         []error{&http.StatusError{StatusCode: 404}},
+			wantAttempts: 2,
+			wantErr:      false,
+		},
+		{
+			name:         "permanent error is not retryable",
+			policy:       model.RetryPolicy{MaxAttempts: 3, Backoff: backoff},
+			errs:         []error{fmt.Errorf("wrapped: %w", permanent.New(errNetwork))},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "deadline has passed",
+			policy:       model.RetryPolicy{MaxAttempts: 3, Backoff: backoff, Deadline: time.Minute},
+			executeAt:    -time.Hour,
+			errs:         []error{errNetwork, errNetwork},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			task := &scheduler.Task{
+				ActionName: "test",
+				Event:      model.ScheduleEvent{ScheduleID: "sid", ExecuteAt: time.Now().Add(tt.executeAt)},
+			}
+			calls := 0
+			handler := func(ctx context.Context, _ *scheduler.Task) error {
+				calls++
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			}
+			attempts, err := retry(context.Background(), tt.policy, task, handler)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %t but got %+v", tt.wantErr, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Fatalf("want %d attempts but got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestRetry_requestTimeout(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		// the first request is slower than the client timeout
+		if atomic.AddInt32(&requests, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	cli := &nethttp.Client{Timeout: 50 * time.Millisecond}
+	handler := func(ctx context.Context, _ *scheduler.Task) error {
+		req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, srv.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := cli.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	policy := model.RetryPolicy{MaxAttempts: 3, Backoff: model.Backoff{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}}
+	task := &scheduler.Task{ActionName: "test", Event: model.ScheduleEvent{ScheduleID: "sid", ExecuteAt: time.Now()}}
+	attempts, err := retry(context.Background(), policy, task, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Fatalf("want 2 attempts but got %d", attempts)
+	}
+}
+
+func TestAction_withRetry(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "deadletters.jsonl")
+	h := history.NewMemory(10)
+	a := &Action{
+		parent:  context.Background(),
+		history: h,
+		configs: map[model.ActionName]model.ActionConfig{
+			"memory": {Name: "memory", DeadLetter: model.DeadLetterConfig{Type: model.DeadLetterMemory}},
+			"file":   {Name: "file", DeadLetter: model.DeadLetterConfig{Type: model.DeadLetterFile, Path: path}},
+		},
+	}
+	handler := a.withRetry(func(context.Context, *scheduler.Task) error {
+		return &http.StatusError{StatusCode: 500, Status: "500 Internal Server Error"}
+	})
+	event := model.ScheduleEvent{ScheduleID: "sid", EventType: model.Start, ExecuteAt: time.Unix(0, 0)}
+	for _, an := range []model.ActionName{"memory", "file"} {
+		if err := handler(context.Background(), &scheduler.Task{ActionName: an, Event: event}); err == nil {
+			t.Fatal("err should not be nil")
+		}
+	}
+
+	got := a.DeadLetters()
+	if len(got) != 1 || got[0].ActionName != "memory" || got[0].Attempts != 1 {
+		t.Fatalf("unexpected dead letters: %+v", got)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dl := model.DeadLetter{}
+	if err := json.Unmarshal(b, &dl); err != nil {
+		t.Fatal(err)
+	}
+	if dl.ActionName != "file" || dl.Event.ScheduleID != "sid" || dl.Error != "unexpected response status: 500 Internal Server Error" {
+		t.Fatalf("unexpected dead letter: %+v", dl)
+	}
+
+	records, err := h.List(model.HistoryQuery{ActionName: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Outcome != model.ExecutionFailed || records[0].StatusCode != 500 ||
+		records[0].Attempts != 1 || records[0].EventID != event.ID("") {
+		t.Fatalf("unexpected history: %+v", records)
+	}
+}
+
+func TestAction_withRetry_deadLetterAction(t *testing.T) {
+	t.Parallel()
+	received := make(chan string, 10)
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		received <- r.URL.Path
+	}))
+	t.Cleanup(srv.Close)
+	ctx := context.Background()
+	deadLetter := model.DeadLetterConfig{Type: model.DeadLetterAction, Action: "fallback"}
+	a := &Action{
+		parent: ctx,
+		configs: map[model.ActionName]model.ActionConfig{
+			"light": {Name: "light", Type: model.ActionHTTP, DeadLetter: deadLetter},
+			"door":  {Name: "door", Type: model.ActionHTTP, DeadLetter: deadLetter},
+			"fallback": {
+				Name:              "fallback",
+				Type:              model.ActionHTTP,
+				HTTPRequestAction: model.HTTPRequestAction{Method: nethttp.MethodPost, URL: srv.URL + "/fallback"},
+			},
+		},
+	}
+	t.Cleanup(func() { a.Shutdown(ctx, time.Second) })
+	fallback, err := a.Configure(a.configs["fallback"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fallback action has its own event of the same schedule
+	event := model.ScheduleEvent{ScheduleID: "sid", EventType: model.Start, ExecuteAt: time.Now().Add(time.Hour)}
+	if err := fallback.Register(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := a.withRetry(func(context.Context, *scheduler.Task) error {
+		return errors.New("failed")
+	})
+	for _, an := range []model.ActionName{"light", "door"} {
+		if err := handler(ctx, &scheduler.Task{ActionName: an, Event: event}); err == nil {
+			t.Fatal("err should not be nil")
+		}
+	}
+	// the same event failed in both actions is executed by the fallback action twice
+	for i := 0; i < 2; i++ {
+		select {
+		case path := <-received:
+			if path != "/fallback" {
+				t.Fatalf("unexpected path: %s", path)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("dead letter is not executed")
+		}
+	}
+
+	// executed dead letters are unregistered, and the fallback action lists its own event only
+	for _, an := range []model.ActionName{"light", "door"} {
+		act, err := a.Configure(model.ActionConfig{Name: deadLetterName("fallback", an), Type: model.ActionHTTP})
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for {
+			events, err := act.List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("dead letters of %s should be unregistered: %+v", an, events)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	events, err := fallback.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID("") != event.ID("") {
+		t.Fatalf("unexpected events of fallback action: %+v", events)
+	}
+}
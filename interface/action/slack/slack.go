@@ -0,0 +1,319 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	httpaction "github.com/ww24/calendar-notifier/interface/action/http"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/permanent"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+const (
+	timeout             = 15 * time.Second
+	namespace           = "slack"
+	postMessageURL      = "https://slack.com/api/chat.postMessage"
+	defaultText         = "{{.Summary}} ({{.EventType}})"
+	maxRateLimitRetries = 3
+	defaultRetryAfter   = time.Second
+	maxRetryAfter       = time.Minute
+)
+
+// transientErrors is errors of Slack Web API which may succeed by retrying.
+// The other errors, e.g. invalid_auth and channel_not_found, are not retried.
+var transientErrors = map[string]bool{
+	"ratelimited":         true,
+	"request_timeout":     true,
+	"service_unavailable": true,
+	"internal_error":      true,
+	"fatal_error":         true,
+}
+
+// Slack implements repository.Action for Slack.
+type Slack struct {
+	cli       *Client
+	name      model.ActionName
+	thread    bool
+	payload   map[string]interface{}
+	templates *Templates
+}
+
+// Client represents Slack client.
+type Client struct {
+	cli       *http.Client
+	scheduler scheduler.Scheduler
+	configs   actionconfig.Lookup
+	apiURL    string
+	// threads holds timestamp of start messages to reply end events in thread.
+	threads map[string]string
+	mu      sync.Mutex
+}
+
+// message is Slack message which is stored in scheduler.
+type message struct {
+	ThreadKey string          `json:"thread_key,omitempty"`
+	EventType model.EventType `json:"event_type"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// response is response of chat.postMessage.
+type response struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+	TS    string `json:"ts"`
+}
+
+// Templates is message templates of Slack action.
+type Templates struct {
+	text   *template.Template
+	blocks *template.Template
+}
+
+// ParseTemplates parses text and blocks templates of Slack action.
+func ParseTemplates(sa model.SlackAction) (*Templates, error) {
+	text := sa.Text
+	if text == "" {
+		text = defaultText
+	}
+	t := &Templates{}
+	var err error
+	if t.text, err = render.Parse("text", text); err != nil {
+		return nil, err
+	}
+	if t.blocks, err = render.Parse("blocks", sa.Blocks); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// render renders message body on top of payload.
+func (t *Templates) render(payload map[string]interface{}, data render.Data) ([]byte, error) {
+	body := make(map[string]interface{}, len(payload)+2)
+	for k, v := range payload {
+		body[k] = v
+	}
+	text, err := render.Execute(t.text, data)
+	if err != nil {
+		return nil, err
+	}
+	body["text"] = text
+	if t.blocks != nil {
+		blocks, err := render.Execute(t.blocks, data)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(blocks)) {
+			return nil, fmt.Errorf("blocks is not valid JSON: %s", blocks)
+		}
+		body["blocks"] = json.RawMessage(blocks)
+	}
+	return json.Marshal(body)
+}
+
+// NewClient returns Slack client.
+func NewClient(ctx context.Context, newScheduler scheduler.Factory, configs actionconfig.Lookup) (*Client, error) {
+	c := &Client{
+		cli:     &http.Client{Timeout: timeout},
+		configs: configs,
+		apiURL:  postMessageURL,
+		threads: make(map[string]string),
+	}
+	s, err := newScheduler(namespace, c.execute)
+	if err != nil {
+		return nil, err
+	}
+	c.scheduler = s
+	return c, nil
+}
+
+func (c *Client) execute(ctx context.Context, task *scheduler.Task) error {
+	m := &message{}
+	if err := json.Unmarshal(task.Payload, m); err != nil {
+		return permanent.New(err)
+	}
+	ac, err := c.configs.Get(task.ActionName)
+	if err != nil {
+		return err
+	}
+	if ac.Slack.WebhookURL != "" {
+		resp, err := c.post(ctx, ac.Slack.WebhookURL, "", m.Body)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		log.Println("[slack action] sent to webhook")
+		scheduler.SetResult(ctx, scheduler.Result{StatusCode: resp.StatusCode})
+		return nil
+	}
+
+	body := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(m.Body, &body); err != nil {
+		return err
+	}
+	body["channel"], _ = json.Marshal(ac.Slack.Channel)
+	if m.ThreadKey != "" && m.EventType == model.End {
+		if ts, ok := c.thread(m.ThreadKey); ok {
+			body["thread_ts"], _ = json.Marshal(ts)
+		}
+	}
+	d, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.post(ctx, c.apiURL, ac.Slack.Token, d)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	res := &response{}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return err
+	}
+	if !res.OK {
+		err := fmt.Errorf("chat.postMessage: %s", res.Error)
+		if !transientErrors[res.Error] {
+			return permanent.New(err)
+		}
+		return err
+	}
+	log.Println("[slack action] posted, ts:", res.TS)
+	scheduler.SetResult(ctx, scheduler.Result{StatusCode: resp.StatusCode, MessageID: res.TS})
+	if m.ThreadKey != "" {
+		c.setThread(m.ThreadKey, m.EventType, res.TS)
+	}
+	return nil
+}
+
+// post posts JSON body and waits for Retry-After while it is rate limited.
+// Body of the response is closed unless it succeeds.
+func (c *Client) post(ctx context.Context, url, token string, body []byte) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := c.cli.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		resp.Body.Close()
+		statusErr := &httpaction.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if resp.StatusCode != http.StatusTooManyRequests || retry >= maxRateLimitRetries {
+			return nil, statusErr
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"))
+		log.Println("[slack action] rate limited, retry after", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, statusErr
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter parses Retry-After header in seconds.
+func retryAfter(v string) time.Duration {
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		return defaultRetryAfter
+	}
+	if d := time.Duration(sec) * time.Second; d < maxRetryAfter {
+		return d
+	}
+	return maxRetryAfter
+}
+
+func (c *Client) thread(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts, ok := c.threads[key]
+	return ts, ok
+}
+
+// setThread remembers start message, and forgets it after end event is replied.
+func (c *Client) setThread(key string, et model.EventType, ts string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch et {
+	case model.Start:
+		c.threads[key] = ts
+	case model.End:
+		delete(c.threads, key)
+	}
+}
+
+// Shutdown shuts down Slack action scheduler and returns abandoned events.
+func (c *Client) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	return c.scheduler.Shutdown(ctx, grace)
+}
+
+// New returns an action for Slack.
+func New(cli *Client, ac model.ActionConfig) (*Slack, error) {
+	t, err := ParseTemplates(ac.Slack)
+	if err != nil {
+		return nil, err
+	}
+	return &Slack{
+		cli:       cli,
+		name:      ac.Name,
+		thread:    ac.Slack.Thread,
+		payload:   ac.Payload,
+		templates: t,
+	}, nil
+}
+
+// List lists schedule events from Slack action scheduler.
+func (a *Slack) List(_ context.Context) (model.ScheduleEvents, error) {
+	return a.cli.scheduler.List(a.name)
+}
+
+// Register renders messages of schedule events and registers them to Slack action scheduler.
+func (a *Slack) Register(_ context.Context, events ...model.ScheduleEvent) error {
+	for _, event := range events {
+		body, err := a.templates.render(a.payload, render.NewData(a.name, event))
+		if err != nil {
+			return err
+		}
+		m := &message{
+			EventType: event.EventType,
+			Body:      body,
+		}
+		if a.thread {
+			m.ThreadKey = string(a.name) + ":" + event.ScheduleID
+		}
+		d, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := a.cli.scheduler.Register(a.name, event, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unregister unregisters schedule events from Slack action scheduler.
+func (a *Slack) Unregister(_ context.Context, events ...model.ScheduleEvent) error {
+	return a.cli.scheduler.Unregister(a.name, events...)
+}
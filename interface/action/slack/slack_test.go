@@ -0,0 +1,220 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	httpaction "github.com/ww24/calendar-notifier/interface/action/http"
+	"github.com/ww24/calendar-notifier/interface/action/internal/actionconfig"
+	"github.com/ww24/calendar-notifier/interface/action/internal/render"
+	"github.com/ww24/calendar-notifier/internal/scheduler"
+)
+
+func TestTemplates_render(t *testing.T) {
+	t.Parallel()
+	event := model.ScheduleEvent{
+		ScheduleID: "id",
+		Summary:    `light "on"`,
+		EventType:  model.Start,
+		ExecuteAt:  time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name    string
+		action  model.SlackAction
+		payload map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "default text",
+			action: model.SlackAction{},
+			want:   `{"text":"light \"on\" (Start)"}`,
+		},
+		{
+			name:   "action name is available",
+			action: model.SlackAction{Text: "{{.ActionName}}: {{.Summary}}"},
+			want:   `{"text":"light: light \"on\""}`,
+		},
+		{
+			name: "blocks are merged into payload",
+			action: model.SlackAction{
+				Text:   "{{.Summary}}",
+				Blocks: `[{"type":"section","text":{"type":"mrkdwn","text":{{json .Summary}}}}]`,
+			},
+			payload: map[string]interface{}{"username": "bot"},
+			want:    `{"blocks":[{"type":"section","text":{"type":"mrkdwn","text":"light \"on\""}}],"text":"light \"on\"","username":"bot"}`,
+		},
+		{
+			name: "blocks should be JSON",
+			action: model.SlackAction{
+				Blocks: `[{"text":{{.Summary}}}]`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tmpl, err := ParseTemplates(tt.action)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tmpl.render(tt.payload, render.NewData("light", event))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("err should not be nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_execute_thread(t *testing.T) {
+	t.Parallel()
+	var requests []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer xoxb-token" {
+			t.Errorf("unexpected authorization: %s", got)
+		}
+		body := make(map[string]interface{})
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		requests = append(requests, body)
+		_ = json.NewEncoder(w).Encode(response{OK: true, TS: fmt.Sprintf("1000.%04d", len(requests))})
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &Client{
+		cli:     srv.Client(),
+		configs: lookup(model.SlackAction{Token: "xoxb-token", Channel: "C0123", Thread: true}),
+		apiURL:  srv.URL,
+		threads: make(map[string]string),
+	}
+	for _, et := range []model.EventType{model.Start, model.End} {
+		task := newTask(t, message{
+			ThreadKey: "light:id",
+			EventType: et,
+			Body:      json.RawMessage(`{"text":"light"}`),
+		})
+		ctx, res := scheduler.WithResult(context.Background())
+		if err := c.execute(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+		if res.MessageID == "" {
+			t.Error("message id should be reported")
+		}
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("unexpected requests: %d", len(requests))
+	}
+	if requests[0]["channel"] != "C0123" {
+		t.Errorf("unexpected channel: %v", requests[0]["channel"])
+	}
+	if _, ok := requests[0]["thread_ts"]; ok {
+		t.Error("start message should not be a reply")
+	}
+	if requests[1]["thread_ts"] != "1000.0001" {
+		t.Errorf("end message should reply to start message: %v", requests[1]["thread_ts"])
+	}
+	if len(c.threads) != 0 {
+		t.Errorf("thread should be forgotten after end event: %v", c.threads)
+	}
+}
+
+func TestClient_execute_rateLimit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		limited     int32
+		wantCalls   int32
+		wantErrCode int
+	}{
+		{
+			name:      "retry after rate limit",
+			limited:   1,
+			wantCalls: 2,
+		},
+		{
+			name:        "give up after retries",
+			limited:     maxRateLimitRetries + 1,
+			wantCalls:   maxRateLimitRetries + 1,
+			wantErrCode: http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tt.limited {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				_, _ = w.Write([]byte("ok"))
+			}))
+			t.Cleanup(srv.Close)
+
+			c := &Client{
+				cli:     srv.Client(),
+				configs: lookup(model.SlackAction{WebhookURL: srv.URL}),
+				threads: make(map[string]string),
+			}
+			task := newTask(t, message{
+				EventType: model.Start,
+				Body:      json.RawMessage(`{"text":"light"}`),
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err := c.execute(ctx, task)
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", got, tt.wantCalls)
+			}
+			if tt.wantErrCode == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var se *httpaction.StatusError
+			if !errors.As(err, &se) || se.StatusCode != tt.wantErrCode {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// lookup returns action config of "light" action.
+func lookup(sa model.SlackAction) actionconfig.Lookup {
+	return func(name model.ActionName) (model.ActionConfig, bool) {
+		return model.ActionConfig{Name: name, Type: model.ActionSlack, Slack: sa}, name == "light"
+	}
+}
+
+func newTask(t *testing.T, m message) *scheduler.Task {
+	t.Helper()
+	d, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &scheduler.Task{ActionName: "light", Payload: d}
+}
@@ -3,10 +3,12 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
@@ -18,6 +20,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
 )
 
 const (
@@ -28,6 +31,9 @@ const (
 // Tasks implements repository.Action for tasks.
 type Tasks struct {
 	cli                 *cloudtasks.Client
+	history             repository.History
+	sem                 chan struct{}
+	name                model.ActionName
 	queuePath           string
 	taskIDPrefix        string
 	serviceAccountEmail string
@@ -41,10 +47,18 @@ type Tasks struct {
 type Client struct {
 	cli       *cloudtasks.Client
 	projectID string
+	history   repository.History
+	// sem limits requests in flight shared by all of actions.
+	sem chan struct{}
 }
 
 // NewClient returns cloud tasks client.
-func NewClient(ctx context.Context) (*Client, error) {
+// Task registrations are recorded to history if it is not nil.
+// Create and delete requests are sent in parallel up to concurrency.
+func NewClient(ctx context.Context, history repository.History, concurrency int) (*Client, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
 	cli, err := cloudtasks.NewClient(ctx)
 	if err != nil {
 		return nil, err
@@ -56,14 +70,24 @@ func NewClient(ctx context.Context) (*Client, error) {
 	c := &Client{
 		cli:       cli,
 		projectID: cred.ProjectID,
+		history:   history,
+		sem:       make(chan struct{}, concurrency),
 	}
 	return c, nil
 }
 
+// Close closes cloud tasks client.
+func (c *Client) Close() error {
+	return c.cli.Close()
+}
+
 // New returns an action for cloud tasks.
 func New(cli *Client, ac model.ActionConfig) *Tasks {
 	return &Tasks{
 		cli:                 cli.cli,
+		history:             cli.history,
+		sem:                 cli.sem,
+		name:                ac.Name,
 		queuePath:           fmt.Sprintf("projects/%s/locations/%s/queues/%s", cli.projectID, ac.Location, ac.Queue),
 		taskIDPrefix:        ac.TaskIDPrefix + string(ac.Name),
 		serviceAccountEmail: ac.ServiceAccountEmail,
@@ -118,6 +142,7 @@ func (a *Tasks) parseTaskName(taskName string) string {
 // Register registeres schedule events to cloud tasks.
 func (a *Tasks) Register(ctx context.Context, events ...model.ScheduleEvent) error {
 	requests := make([]*taskspb.CreateTaskRequest, 0, len(events))
+	scheduled := make(map[string]model.ScheduleEvent, len(events))
 	for _, event := range events {
 		var body []byte
 		if a.payload != nil {
@@ -165,24 +190,95 @@ func (a *Tasks) Register(ctx context.Context, events ...model.ScheduleEvent) err
 		}
 		log.Println("[tasks action] register, task_name:", req.Task.Name)
 		requests = append(requests, req)
+		scheduled[req.Task.Name] = event
 	}
-	return a.registerTasks(ctx, requests...)
+	return a.registerTasks(ctx, scheduled, requests...)
 }
 
-func (a *Tasks) registerTasks(ctx context.Context, requests ...*taskspb.CreateTaskRequest) error {
-	for _, req := range requests {
+func (a *Tasks) registerTasks(ctx context.Context, scheduled map[string]model.ScheduleEvent, requests ...*taskspb.CreateTaskRequest) error {
+	errs := a.parallel(ctx, len(requests), func(ctx context.Context, i int) error {
+		req := requests[i]
+		startedAt := time.Now()
 		_, err := a.cli.CreateTask(ctx, req)
+		if status.Code(err) != codes.AlreadyExists {
+			a.record(scheduled[req.Task.Name], req.Task.Name, startedAt, err)
+		}
 		if err != nil {
 			switch status.Code(err) {
 			case codes.AlreadyExists:
 				log.Printf("[tasks action] already exists, task_name: %v\n", req.Task.Name)
-				continue
+				return nil
 			default:
 				return err
 			}
 		}
+		return nil
+	})
+	return eventErrors(errs, func(i int) model.ScheduleEvent { return scheduled[requests[i].Task.Name] })
+}
+
+// parallel calls fn for n requests in parallel within the limit of the client, and returns errors of the requests.
+// Every request is sent even if the others fail, and requests which have not been sent fail when ctx is done.
+func (a *Tasks) parallel(ctx context.Context, n int, fn func(ctx context.Context, i int) error) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case a.sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return errs
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-a.sem }()
+			errs[i] = fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// eventErrors joins errors of requests as model.EventError of the event returned by event,
+// so that sync regards only the events of failed requests as failed.
+func eventErrors(errs []error, event func(i int) model.ScheduleEvent) error {
+	eventErrs := make([]error, 0, len(errs))
+	for i, err := range errs {
+		if err != nil {
+			eventErrs = append(eventErrs, &model.EventError{Event: event(i), Err: err})
+		}
+	}
+	return errors.Join(eventErrs...)
+}
+
+// record records task registration to history.
+func (a *Tasks) record(event model.ScheduleEvent, taskName string, startedAt time.Time, err error) {
+	if a.history == nil {
+		return
+	}
+	e := model.Execution{
+		ActionName:    a.name,
+		ActionType:    model.ActionTasks,
+		EventID:       event.ID(""),
+		EventType:     event.EventType,
+		ScheduledAt:   event.ExecuteAt,
+		StartedAt:     startedAt,
+		Attempts:      1,
+		Outcome:       model.ExecutionRegistered,
+		MessageID:     taskName,
+		LatencyMillis: time.Since(startedAt).Milliseconds(),
+	}
+	if err != nil {
+		e.Outcome = model.ExecutionFailed
+		e.Error = err.Error()
+	}
+	if err := a.history.Record(e); err != nil {
+		log.Println("[history] record error:", err)
 	}
-	return nil
 }
 
 // Unregister unregisters schedule events from cloud tasks.
@@ -195,14 +291,12 @@ func (a *Tasks) Unregister(ctx context.Context, events ...model.ScheduleEvent) e
 		log.Println("[tasks action] unregister, task_name:", req.Name)
 		requests = append(requests, req)
 	}
-	return a.unregisterTasks(ctx, requests...)
+	return a.unregisterTasks(ctx, events, requests...)
 }
 
-func (a *Tasks) unregisterTasks(ctx context.Context, requests ...*taskspb.DeleteTaskRequest) error {
-	for _, req := range requests {
-		if err := a.cli.DeleteTask(ctx, req); err != nil {
-			return err
-		}
-	}
-	return nil
+func (a *Tasks) unregisterTasks(ctx context.Context, events []model.ScheduleEvent, requests ...*taskspb.DeleteTaskRequest) error {
+	errs := a.parallel(ctx, len(requests), func(ctx context.Context, i int) error {
+		return a.cli.DeleteTask(ctx, requests[i])
+	})
+	return eventErrors(errs, func(i int) model.ScheduleEvent { return events[i] })
 }
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTasks_parallel(t *testing.T) {
+	t.Parallel()
+	errRequest := errors.New("request error")
+	tests := []struct {
+		name   string
+		limit  int
+		n      int
+		failAt int
+	}{
+		{
+			name:   "all of requests succeed",
+			limit:  3,
+			n:      10,
+			failAt: -1,
+		},
+		{
+			name:   "send every request even if one fails",
+			limit:  1,
+			n:      10,
+			failAt: 2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := &Tasks{sem: make(chan struct{}, tt.limit)}
+			var calls, inFlight, maxInFlight int32
+			errs := a.parallel(context.Background(), tt.n, func(ctx context.Context, i int) error {
+				atomic.AddInt32(&calls, 1)
+				cur := atomic.AddInt32(&inFlight, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if cur <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, cur) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				if i == tt.failAt {
+					return errRequest
+				}
+				return nil
+			})
+			for i, err := range errs {
+				if wantErr := i == tt.failAt; (err != nil) != wantErr {
+					t.Fatalf("request %d: want error %t but got %+v", i, wantErr, err)
+				}
+			}
+			if got := atomic.LoadInt32(&calls); got != int32(tt.n) {
+				t.Fatalf("all of requests should be sent but sent %d of %d", got, tt.n)
+			}
+			if got := atomic.LoadInt32(&maxInFlight); got > int32(tt.limit) {
+				t.Fatalf("requests in flight should be limited to %d but got %d", tt.limit, got)
+			}
+		})
+	}
+}
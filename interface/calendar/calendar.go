@@ -12,6 +12,14 @@ import (
 	"github.com/ww24/calendar-notifier/domain/repository"
 )
 
+// access roles which can not read event details.
+const (
+	accessRoleFreeBusyReader = "freeBusyReader"
+	accessRoleNone           = "none"
+)
+
+const responseStatusDeclined = "declined"
+
 // Calendar is calendar API wrapper.
 type Calendar struct {
 	calendarID string
@@ -29,35 +37,42 @@ func New(config repository.Config) *Calendar {
 }
 
 // List lists schedules from google calendar.
+// All of pages are read, and it fails if the calendar is shared without event details,
+// because every schedule would be regarded as deleted.
 func (c *Calendar) List(ctx context.Context, since, until time.Time) (model.Schedules, error) {
 	svc, err := c.newService(ctx)
 	if err != nil {
 		return nil, err
 	}
-	events, err := svc.Events.List(c.calendarID).
+	schedules := make([]model.Schedule, 0)
+	err = svc.Events.List(c.calendarID).
 		ShowDeleted(false).
 		SingleEvents(true).
 		TimeMin(since.Format(time.RFC3339)).
 		TimeMax(until.Format(time.RFC3339)).
 		OrderBy("startTime").
-		Do()
+		Pages(ctx, func(events *calendar.Events) error {
+			switch events.AccessRole {
+			case accessRoleFreeBusyReader, accessRoleNone:
+				return fmt.Errorf("%w: access role is %s", model.ErrAnomalousCalendarRead, events.AccessRole)
+			}
+			for _, item := range events.Items {
+				s, err := toModelSchedule(item)
+				if err != nil {
+					log.Printf("Warn: %+v\n", err)
+					continue
+				}
+				if s.StartAt.IsZero() || s.EndAt.IsZero() {
+					continue
+				}
+				schedules = append(schedules, s)
+			}
+			return nil
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	schedules := make([]model.Schedule, 0, len(events.Items))
-	for _, item := range events.Items {
-		s, err := toModelSchedule(item)
-		if err != nil {
-			log.Printf("Warn: %+v\n", err)
-			continue
-		}
-		if s.StartAt.IsZero() || s.EndAt.IsZero() {
-			continue
-		}
-		schedules = append(schedules, s)
-	}
-
 	return schedules, nil
 }
 
@@ -66,6 +81,7 @@ func toModelSchedule(item *calendar.Event) (model.Schedule, error) {
 		ID:          item.Id,
 		Summary:     item.Summary,
 		Description: item.Description,
+		Attendees:   attendees(item),
 	}
 	t, err := parseDate(item.Start.DateTime)
 	if err != nil {
@@ -80,6 +96,18 @@ func toModelSchedule(item *calendar.Event) (model.Schedule, error) {
 	return s, nil
 }
 
+// attendees returns email addresses of attendees except for resources and those who declined.
+func attendees(item *calendar.Event) []string {
+	emails := make([]string, 0, len(item.Attendees))
+	for _, a := range item.Attendees {
+		if a.Resource || a.Email == "" || a.ResponseStatus == responseStatusDeclined {
+			continue
+		}
+		emails = append(emails, a.Email)
+	}
+	return emails
+}
+
 func parseDate(dt string) (time.Time, error) {
 	t, err := time.Parse(time.RFC3339, dt)
 	if err != nil {
@@ -0,0 +1,123 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+func newTestCalendar(t *testing.T, pages map[string]*calendar.Events) *Calendar {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Query().Get("pageToken")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	t.Cleanup(srv.Close)
+	return &Calendar{
+		calendarID: "calendar",
+		newService: func(ctx context.Context) (*calendar.Service, error) {
+			return calendar.NewService(ctx, option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+		},
+	}
+}
+
+func event(id, start, end string) *calendar.Event {
+	return &calendar.Event{
+		Id:      id,
+		Summary: "test",
+		Start:   &calendar.EventDateTime{DateTime: start},
+		End:     &calendar.EventDateTime{DateTime: end},
+	}
+}
+
+func TestCalendar_List(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		pages   map[string]*calendar.Events
+		wantIDs []string
+		wantErr error
+	}{
+		{
+			name: "read all of pages",
+			pages: map[string]*calendar.Events{
+				"": {
+					AccessRole:    "reader",
+					Items:         []*calendar.Event{event("sid1", "2022-01-01T01:00:00Z", "2022-01-01T02:00:00Z")},
+					NextPageToken: "empty",
+				},
+				"empty": {
+					AccessRole:    "reader",
+					NextPageToken: "last",
+				},
+				"last": {
+					AccessRole: "reader",
+					Items:      []*calendar.Event{event("sid2", "2022-01-01T03:00:00Z", "2022-01-01T04:00:00Z")},
+				},
+			},
+			wantIDs: []string{"sid1", "sid2"},
+		},
+		{
+			name: "calendar shared without event details",
+			pages: map[string]*calendar.Events{
+				"": {
+					AccessRole: "freeBusyReader",
+					Items:      []*calendar.Event{event("sid1", "2022-01-01T01:00:00Z", "2022-01-01T02:00:00Z")},
+				},
+			},
+			wantErr: model.ErrAnomalousCalendarRead,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := newTestCalendar(t, tt.pages)
+			schedules, err := c.List(context.Background(), ts, ts.Add(24*time.Hour))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %+v but got %+v", tt.wantErr, err)
+			}
+			if len(schedules) != len(tt.wantIDs) {
+				t.Fatalf("want %d schedules but got %d", len(tt.wantIDs), len(schedules))
+			}
+			for i, s := range schedules {
+				if s.ID != tt.wantIDs[i] {
+					t.Fatalf("want %s but got %s", tt.wantIDs[i], s.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestAttendees(t *testing.T) {
+	t.Parallel()
+	item := &calendar.Event{
+		Attendees: []*calendar.EventAttendee{
+			{Email: "alice@example.com", ResponseStatus: "accepted"},
+			{Email: "bob@example.com", ResponseStatus: "declined"},
+			{Email: "room@resource.calendar.google.com", Resource: true},
+			{Email: "carol@example.com", ResponseStatus: "needsAction"},
+		},
+	}
+	got := attendees(item)
+	want := []string{"alice@example.com", "carol@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v but got %v", want, got)
+	}
+}
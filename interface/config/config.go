@@ -5,48 +5,240 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
+	amqp091 "github.com/rabbitmq/amqp091-go"
 	"gopkg.in/yaml.v3"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/interface/action/amqp"
+	"github.com/ww24/calendar-notifier/interface/action/email"
+	"github.com/ww24/calendar-notifier/interface/action/grpc"
+	"github.com/ww24/calendar-notifier/interface/action/kafka"
+	"github.com/ww24/calendar-notifier/interface/action/mqtt"
+	"github.com/ww24/calendar-notifier/interface/action/nats"
+	"github.com/ww24/calendar-notifier/interface/action/registry"
+	"github.com/ww24/calendar-notifier/interface/action/slack"
 )
 
 const (
-	defaultInterval = 1 * time.Minute
+	defaultInterval               = 1 * time.Minute
+	defaultMaxConsecutiveFailures = 10
+	defaultShutdownTimeout        = 30 * time.Second
+	defaultLeaseName              = "calendar-notifier"
+	defaultLeaseDuration          = 15 * time.Second
+	defaultLeaderRetryPeriod      = 2 * time.Second
+	defaultLaunchRetryAfter       = 30 * time.Second
+	defaultActionConcurrency      = 4
+	defaultTasksConcurrency       = 10
+	// Version is the latest config version.
+	Version = "2"
 )
 
 // Config represents a config.yml.
 type Config struct {
-	Version    string                      `yaml:"version"`
-	Mode       model.RunningMode           `yaml:"mode"`
-	Interval   time.Duration               `yaml:"interval"`
-	CalendarID string                      `yaml:"calendar_id"`
-	Handler    map[string]EventHandler     `yaml:"handler"`
-	Action     map[model.ActionName]Action `yaml:"action"`
+	Version    string            `yaml:"version"`
+	Mode       model.RunningMode `yaml:"mode,omitempty"`
+	Interval   Duration          `yaml:"interval,omitempty"`
+	CalendarID string            `yaml:"calendar_id"`
+	DryRun     bool              `yaml:"dry_run,omitempty"`
+	Worker     *Worker           `yaml:"worker,omitempty"`
+	Shutdown   *Shutdown         `yaml:"shutdown,omitempty"`
+	Scheduler  *Scheduler        `yaml:"scheduler,omitempty"`
+	History    *History          `yaml:"history,omitempty"`
+	Launch     *Launch           `yaml:"launch,omitempty"`
+	SyncGuard  *SyncGuard        `yaml:"sync_guard,omitempty"`
+	// Concurrency limits concurrent requests on sync to keep within API quotas.
+	Concurrency *Concurrency `yaml:"concurrency,omitempty"`
+	// LeaderElection elects the only replica which syncs and executes actions.
+	LeaderElection *LeaderElection `yaml:"leader_election,omitempty"`
+	Handlers       []Handler       `yaml:"handlers"`
+	Actions        []Action        `yaml:"actions"`
+
+	sourceVersion string
+	handlerMap    map[string]*Handler
+	actionMap     map[model.ActionName]*Action
+}
+
+// Worker is configuration of resident worker.
+type Worker struct {
+	MaxConsecutiveFailures int      `yaml:"max_consecutive_failures,omitempty"`
+	Backoff                *Backoff `yaml:"backoff,omitempty"`
+}
+
+// Shutdown is configuration of graceful shutdown.
+type Shutdown struct {
+	Timeout             Duration `yaml:"timeout,omitempty"`
+	GraceWindow         Duration `yaml:"grace_window,omitempty"`
+	AbandonedEventsFile string   `yaml:"abandoned_events_file,omitempty"`
+}
+
+// Scheduler is configuration of scheduler for actions except for tasks.
+type Scheduler struct {
+	Type    model.SchedulerType `yaml:"type,omitempty"`
+	Path    string              `yaml:"path,omitempty"`
+	Misfire *MisfirePolicy      `yaml:"misfire,omitempty"`
+}
+
+// History is configuration of execution history store.
+type History struct {
+	Type model.HistoryType `yaml:"type,omitempty"`
+	Path string            `yaml:"path,omitempty"`
+	Size int               `yaml:"size,omitempty"`
+}
+
+// Launch is configuration of launch request in ondemand mode.
+type Launch struct {
+	OnConflict model.LaunchConflictPolicy `yaml:"on_conflict,omitempty"`
+	RetryAfter Duration                   `yaml:"retry_after,omitempty"`
+}
+
+// Concurrency is configuration of concurrent requests on sync.
+type Concurrency struct {
+	Actions int `yaml:"actions,omitempty"`
+	Tasks   int `yaml:"tasks,omitempty"`
+}
+
+// SyncGuard is configuration of safeguards against mass unregistration.
+type SyncGuard struct {
+	MaxUnregister      int     `yaml:"max_unregister,omitempty"`
+	MaxUnregisterRatio float64 `yaml:"max_unregister_ratio,omitempty"`
+	AllowEmptyCalendar bool    `yaml:"allow_empty_calendar,omitempty"`
+}
+
+// LeaderElection is configuration of leader election between resident replicas.
+// In ondemand mode, the lock is held during each sync instead.
+type LeaderElection struct {
+	Type          model.LeaderElectionType `yaml:"type"`
+	Path          string                   `yaml:"path,omitempty"`
+	Namespace     string                   `yaml:"namespace,omitempty"`
+	Name          string                   `yaml:"name,omitempty"`
+	Identity      string                   `yaml:"identity,omitempty"`
+	APIServer     string                   `yaml:"api_server,omitempty"`
+	LeaseDuration Duration                 `yaml:"lease_duration,omitempty"`
+	RetryPeriod   Duration                 `yaml:"retry_period,omitempty"`
+}
+
+// Backoff is configuration of exponential backoff.
+type Backoff struct {
+	InitialInterval Duration `yaml:"initial_interval,omitempty"`
+	MaxInterval     Duration `yaml:"max_interval,omitempty"`
+	Multiplier      float64  `yaml:"multiplier,omitempty"`
+}
+
+func (b *Backoff) toModel() model.Backoff {
+	if b == nil {
+		return model.Backoff{}
+	}
+	return model.Backoff{
+		InitialInterval: time.Duration(b.InitialInterval),
+		MaxInterval:     time.Duration(b.MaxInterval),
+		Multiplier:      b.Multiplier,
+	}
 }
 
-// EventHandler is event handler which contains action names.
-type EventHandler struct {
-	Start []model.ActionName `yaml:"start"`
-	End   []model.ActionName `yaml:"end"`
+// Handler is event handler rule which contains action names.
+type Handler struct {
+	Summary string             `yaml:"summary"`
+	Start   []model.ActionName `yaml:"start,omitempty"`
+	End     []model.ActionName `yaml:"end,omitempty"`
+	// Misfire is policy for start event which has passed before it is registered.
+	Misfire *MisfirePolicy `yaml:"misfire,omitempty"`
 }
 
 // Action is action definition.
 type Action struct {
-	Type              model.ActionType `yaml:"type"`
-	HTTPRequestAction `yaml:",inline"`
-	CloudPubSubAction `yaml:",inline"`
-	CloudTasksAction  `yaml:",inline"`
-	Payload           map[string]interface{} `yaml:"payload"`
+	Name    model.ActionName       `yaml:"name"`
+	Type    model.ActionType       `yaml:"type"`
+	HTTP    *HTTPRequestAction     `yaml:"http,omitempty"`
+	PubSub  *CloudPubSubAction     `yaml:"pubsub,omitempty"`
+	Tasks   *CloudTasksAction      `yaml:"tasks,omitempty"`
+	Slack   *SlackAction           `yaml:"slack,omitempty"`
+	Email   *EmailAction           `yaml:"email,omitempty"`
+	Exec    *ExecAction            `yaml:"exec,omitempty"`
+	MQTT    *MQTTAction            `yaml:"mqtt,omitempty"`
+	NATS    *NATSAction            `yaml:"nats,omitempty"`
+	AMQP    *AMQPAction            `yaml:"amqp,omitempty"`
+	Kafka   *KafkaAction           `yaml:"kafka,omitempty"`
+	GRPC    *GRPCAction            `yaml:"grpc,omitempty"`
+	Plugin  *PluginAction          `yaml:"plugin,omitempty"`
+	Payload map[string]interface{} `yaml:"payload,omitempty"`
+	// Retry and DeadLetter are available for actions except for tasks.
+	Retry      *Retry      `yaml:"retry,omitempty"`
+	DeadLetter *DeadLetter `yaml:"dead_letter,omitempty"`
+	// Blocks is config blocks of action types registered to registry.
+	Blocks map[string]yaml.Node `yaml:",inline"`
+	// params is decoded config block of registered action type.
+	params interface{}
+}
+
+// validateRegistered decodes config block of action type registered to registry,
+// and validates it by the registered factory.
+func (a *Action) validateRegistered() error {
+	f, ok := registry.Lookup(a.Type)
+	if !ok {
+		return fmt.Errorf("unsupported action type: %s", a.Type)
+	}
+	if a.Retry != nil || a.DeadLetter != nil {
+		return fmt.Errorf("retry and dead_letter are not supported by %s action", a.Type)
+	}
+	block, ok := a.Blocks[string(a.Type)]
+	if !ok {
+		return fmt.Errorf("%s block is required", a.Type)
+	}
+	params := f.NewParams()
+	if err := block.Decode(params); err != nil {
+		return fmt.Errorf("%s block: %w", a.Type, err)
+	}
+	if err := f.Validate(params); err != nil {
+		return fmt.Errorf("%s: %w", a.Type, err)
+	}
+	a.params = params
+	return nil
+}
+
+// Retry is retry configuration of action execution.
+type Retry struct {
+	MaxAttempts          int      `yaml:"max_attempts,omitempty"`
+	Backoff              *Backoff `yaml:"backoff,omitempty"`
+	RetryableStatusCodes []int    `yaml:"retryable_status_codes,omitempty"`
+	Deadline             Duration `yaml:"deadline,omitempty"`
+}
+
+func (r *Retry) toModel() model.RetryPolicy {
+	if r == nil {
+		return model.RetryPolicy{}
+	}
+	return model.RetryPolicy{
+		MaxAttempts:          r.MaxAttempts,
+		Backoff:              r.Backoff.toModel(),
+		RetryableStatusCodes: r.RetryableStatusCodes,
+		Deadline:             time.Duration(r.Deadline),
+	}
+}
+
+// DeadLetter is configuration of dead-letter sink.
+type DeadLetter struct {
+	Type   model.DeadLetterType `yaml:"type"`
+	Path   string               `yaml:"path,omitempty"`
+	Action model.ActionName     `yaml:"action,omitempty"`
+}
+
+func (d *DeadLetter) toModel() model.DeadLetterConfig {
+	if d == nil {
+		return model.DeadLetterConfig{}
+	}
+	return model.DeadLetterConfig(*d)
 }
 
 // HTTPRequestAction is configuration of HTTP action.
 type HTTPRequestAction struct {
-	Method string      `yaml:"method"`
-	Header http.Header `yaml:"header"`
+	Method string      `yaml:"method,omitempty"`
+	Header http.Header `yaml:"header,omitempty"`
 	URL    string      `yaml:"url"`
 }
 
@@ -59,25 +251,416 @@ type CloudPubSubAction struct {
 type CloudTasksAction struct {
 	Location            string `yaml:"location"`
 	Queue               string `yaml:"queue"`
-	TaskIDPrefix        string `yaml:"task_id_prefix"`
-	ServiceAccountEmail string `yaml:"service_account_email"`
+	TaskIDPrefix        string `yaml:"task_id_prefix,omitempty"`
+	ServiceAccountEmail string `yaml:"service_account_email,omitempty"`
+	HTTPRequestAction   `yaml:",inline"`
+}
+
+// SlackAction is configuration of Slack action.
+type SlackAction struct {
+	WebhookURL string `yaml:"webhook_url,omitempty"`
+	Token      string `yaml:"token,omitempty"`
+	Channel    string `yaml:"channel,omitempty"`
+	Text       string `yaml:"text,omitempty"`
+	Blocks     string `yaml:"blocks,omitempty"`
+	Thread     bool   `yaml:"thread,omitempty"`
+}
+
+func (s *SlackAction) validate() error {
+	switch {
+	case s.WebhookURL != "" && s.Token != "":
+		return errors.New("slack.webhook_url and slack.token are exclusive")
+	case s.WebhookURL == "" && s.Token == "":
+		return errors.New("slack.webhook_url or slack.token is required")
+	case s.Token != "" && s.Channel == "":
+		return errors.New("slack.channel is required with slack.token")
+	case s.Thread && s.Token == "":
+		return errors.New("slack.thread requires slack.token because webhook does not return message timestamp")
+	}
+	if _, err := slack.ParseTemplates(model.SlackAction(*s)); err != nil {
+		return fmt.Errorf("slack template: %w", err)
+	}
+	return nil
+}
+
+// EmailAction is configuration of email action.
+type EmailAction struct {
+	SMTP      SMTP     `yaml:"smtp"`
+	From      string   `yaml:"from"`
+	To        []string `yaml:"to,omitempty"`
+	Attendees bool     `yaml:"attendees,omitempty"`
+	Subject   string   `yaml:"subject,omitempty"`
+	Text      string   `yaml:"text,omitempty"`
+	HTML      string   `yaml:"html,omitempty"`
+}
+
+// SMTP is configuration of SMTP server.
+// Port defaults to 587 for starttls, 465 for implicit and 25 for none.
+type SMTP struct {
+	Host     string            `yaml:"host"`
+	Port     int               `yaml:"port,omitempty"`
+	Username string            `yaml:"username,omitempty"`
+	Password string            `yaml:"password,omitempty"`
+	TLS      model.SMTPTLSMode `yaml:"tls,omitempty"`
+}
+
+func (e *EmailAction) validate() error {
+	if e.SMTP.Host == "" {
+		return errors.New("email.smtp.host is required")
+	}
+	switch e.SMTP.TLS {
+	case "", model.SMTPTLSStartTLS, model.SMTPTLSImplicit, model.SMTPTLSNone:
+	default:
+		return fmt.Errorf("unsupported email.smtp.tls: %s", e.SMTP.TLS)
+	}
+	if e.SMTP.Port < 0 || e.SMTP.Port > 65535 {
+		return fmt.Errorf("invalid email.smtp.port: %d", e.SMTP.Port)
+	}
+	// net/smtp refuses to send password in plain text except to localhost.
+	if e.SMTP.TLS == model.SMTPTLSNone && e.SMTP.Username != "" && !isLocalhost(e.SMTP.Host) {
+		return errors.New("email.smtp.username requires TLS except for localhost")
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("email.from: %w", err)
+	}
+	if len(e.To) == 0 && !e.Attendees {
+		return errors.New("email.to or email.attendees is required")
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("email.to: %w", err)
+		}
+	}
+	if _, err := email.ParseTemplates(e.toModel()); err != nil {
+		return fmt.Errorf("email template: %w", err)
+	}
+	return nil
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func (e *EmailAction) toModel() model.EmailAction {
+	smtp := model.SMTPConfig(e.SMTP)
+	if smtp.TLS == "" {
+		smtp.TLS = model.SMTPTLSStartTLS
+	}
+	if smtp.Port == 0 {
+		switch smtp.TLS {
+		case model.SMTPTLSImplicit:
+			smtp.Port = 465
+		case model.SMTPTLSNone:
+			smtp.Port = 25
+		default:
+			smtp.Port = 587
+		}
+	}
+	return model.EmailAction{
+		SMTP:      smtp,
+		From:      e.From,
+		To:        e.To,
+		Attendees: e.Attendees,
+		Subject:   e.Subject,
+		Text:      e.Text,
+		HTML:      e.HTML,
+	}
+}
+
+// ExecAction is configuration of exec action.
+type ExecAction struct {
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args,omitempty"`
+	Dir     string            `yaml:"dir,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	Timeout Duration          `yaml:"timeout,omitempty"`
+}
+
+func (e *ExecAction) validate() error {
+	if e.Command == "" {
+		return errors.New("exec.command is required")
+	}
+	if e.Timeout < 0 {
+		return errors.New("exec.timeout should not be negative")
+	}
+	for k := range e.Env {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("invalid exec.env name: %q", k)
+		}
+	}
+	return nil
+}
+
+// MQTTAction is configuration of MQTT publish action.
+type MQTTAction struct {
+	Broker   string `yaml:"broker"`
+	ClientID string `yaml:"client_id,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	TLS      *TLS   `yaml:"tls,omitempty"`
+	Topic    string `yaml:"topic"`
+	Message  string `yaml:"message,omitempty"`
+	QoS      byte   `yaml:"qos,omitempty"`
+	Retain   bool   `yaml:"retain,omitempty"`
+}
+
+// NATSAction is configuration of NATS publish action.
+type NATSAction struct {
+	URL       string `yaml:"url"`
+	CredsFile string `yaml:"creds_file,omitempty"`
+	TLS       *TLS   `yaml:"tls,omitempty"`
+	Subject   string `yaml:"subject"`
+	Message   string `yaml:"message,omitempty"`
+	JetStream bool   `yaml:"jetstream,omitempty"`
+}
+
+func (n *NATSAction) validate() error {
+	if n.URL == "" {
+		return errors.New("nats.url is required")
+	}
+	if n.Subject == "" {
+		return errors.New("nats.subject is required")
+	}
+	if n.TLS != nil && (n.TLS.CertFile == "") != (n.TLS.KeyFile == "") {
+		return errors.New("nats.tls.cert_file and nats.tls.key_file should be set together")
+	}
+	if _, err := nats.ParseTemplates(n.toModel()); err != nil {
+		return fmt.Errorf("nats template: %w", err)
+	}
+	return nil
+}
+
+func (n *NATSAction) toModel() model.NATSAction {
+	return model.NATSAction{
+		URL:       n.URL,
+		CredsFile: n.CredsFile,
+		TLS:       n.TLS.toModel(),
+		Subject:   n.Subject,
+		Message:   n.Message,
+		JetStream: n.JetStream,
+	}
+}
+
+// AMQPAction is configuration of AMQP publish action.
+type AMQPAction struct {
+	URL         string            `yaml:"url"`
+	Exchange    string            `yaml:"exchange,omitempty"`
+	RoutingKey  string            `yaml:"routing_key"`
+	Message     string            `yaml:"message,omitempty"`
+	ContentType string            `yaml:"content_type,omitempty"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	Transient   bool              `yaml:"transient,omitempty"`
+}
+
+func (a *AMQPAction) validate() error {
+	if a.URL == "" {
+		return errors.New("amqp.url is required")
+	}
+	if _, err := amqp091.ParseURI(a.URL); err != nil {
+		return fmt.Errorf("amqp.url: %w", err)
+	}
+	if a.Exchange == "" && a.RoutingKey == "" {
+		return errors.New("amqp.routing_key is required for default exchange")
+	}
+	if _, err := amqp.ParseTemplates(a.toModel()); err != nil {
+		return fmt.Errorf("amqp template: %w", err)
+	}
+	return nil
+}
+
+func (a *AMQPAction) toModel() model.AMQPAction {
+	return model.AMQPAction(*a)
+}
+
+// KafkaAction is configuration of Kafka produce action.
+type KafkaAction struct {
+	Brokers []string          `yaml:"brokers"`
+	Topic   string            `yaml:"topic"`
+	Acks    string            `yaml:"acks,omitempty"`
+	SASL    *KafkaSASL        `yaml:"sasl,omitempty"`
+	TLS     *TLS              `yaml:"tls,omitempty"`
+	Message string            `yaml:"message,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// KafkaSASL is configuration of SASL authentication.
+type KafkaSASL struct {
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+func (k *KafkaAction) validate() error {
+	if len(k.Brokers) == 0 {
+		return errors.New("kafka.brokers is required")
+	}
+	if k.Topic == "" {
+		return errors.New("kafka.topic is required")
+	}
+	if _, err := kafka.ParseAcks(k.Acks); err != nil {
+		return fmt.Errorf("kafka.acks: %w", err)
+	}
+	if k.SASL != nil {
+		switch k.SASL.Mechanism {
+		case "plain", "scram-sha-256", "scram-sha-512":
+		default:
+			return fmt.Errorf("kafka.sasl.mechanism should be plain, scram-sha-256 or scram-sha-512: %q", k.SASL.Mechanism)
+		}
+		if k.SASL.Username == "" {
+			return errors.New("kafka.sasl.username is required")
+		}
+	}
+	if k.TLS != nil && (k.TLS.CertFile == "") != (k.TLS.KeyFile == "") {
+		return errors.New("kafka.tls.cert_file and kafka.tls.key_file should be set together")
+	}
+	if _, err := kafka.ParseTemplates(k.toModel()); err != nil {
+		return fmt.Errorf("kafka template: %w", err)
+	}
+	return nil
+}
+
+func (k *KafkaAction) toModel() model.KafkaAction {
+	ka := model.KafkaAction{
+		Brokers: k.Brokers,
+		Topic:   k.Topic,
+		Acks:    k.Acks,
+		Message: k.Message,
+		Headers: k.Headers,
+	}
+	if k.SASL != nil {
+		ka.SASL = model.KafkaSASL(*k.SASL)
+	}
+	if k.TLS != nil {
+		tc := k.TLS.toModel()
+		ka.TLS = &tc
+	}
+	return ka
+}
+
+// GRPCAction is configuration of gRPC unary call action.
+type GRPCAction struct {
+	Target        string            `yaml:"target"`
+	Method        string            `yaml:"method"`
+	DescriptorSet string            `yaml:"descriptor_set,omitempty"`
+	Request       string            `yaml:"request,omitempty"`
+	Metadata      map[string]string `yaml:"metadata,omitempty"`
+	TLS           *TLS              `yaml:"tls,omitempty"`
+}
+
+func (g *GRPCAction) validate() error {
+	if g.Target == "" {
+		return errors.New("grpc.target is required")
+	}
+	if _, _, err := grpc.ParseMethod(g.Method); err != nil {
+		return fmt.Errorf("grpc.method: %w", err)
+	}
+	if g.DescriptorSet != "" {
+		if _, err := grpc.LoadMethod(g.DescriptorSet, g.Method); err != nil {
+			return fmt.Errorf("grpc.descriptor_set: %w", err)
+		}
+	}
+	if g.TLS != nil && (g.TLS.CertFile == "") != (g.TLS.KeyFile == "") {
+		return errors.New("grpc.tls.cert_file and grpc.tls.key_file should be set together")
+	}
+	if _, err := grpc.ParseTemplate(g.toModel()); err != nil {
+		return fmt.Errorf("grpc template: %w", err)
+	}
+	return nil
+}
+
+func (g *GRPCAction) toModel() model.GRPCAction {
+	ga := model.GRPCAction{
+		Target:        g.Target,
+		Method:        g.Method,
+		DescriptorSet: g.DescriptorSet,
+		Request:       g.Request,
+		Metadata:      g.Metadata,
+	}
+	if g.TLS != nil {
+		tc := g.TLS.toModel()
+		ga.TLS = &tc
+	}
+	return ga
+}
+
+// PluginAction is configuration of external plugin action.
+type PluginAction struct {
+	Path    string                 `yaml:"path"`
+	Args    []string               `yaml:"args,omitempty"`
+	Options map[string]interface{} `yaml:"options,omitempty"`
+	Timeout Duration               `yaml:"timeout,omitempty"`
+}
+
+func (p *PluginAction) validate() error {
+	if p.Path == "" {
+		return errors.New("plugin.path is required")
+	}
+	if p.Timeout < 0 {
+		return errors.New("plugin.timeout should not be negative")
+	}
+	return nil
+}
+
+// TLS is configuration of TLS client.
+type TLS struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+func (t *TLS) toModel() model.TLSConfig {
+	if t == nil {
+		return model.TLSConfig{}
+	}
+	return model.TLSConfig(*t)
+}
+
+func (m *MQTTAction) validate() error {
+	u, err := url.Parse(m.Broker)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid mqtt.broker: %q", m.Broker)
+	}
+	if m.Topic == "" {
+		return errors.New("mqtt.topic is required")
+	}
+	if m.QoS > 2 {
+		return fmt.Errorf("mqtt.qos should be 0, 1 or 2: %d", m.QoS)
+	}
+	if m.TLS != nil && (m.TLS.CertFile == "") != (m.TLS.KeyFile == "") {
+		return errors.New("mqtt.tls.cert_file and mqtt.tls.key_file should be set together")
+	}
+	if _, err := mqtt.ParseTemplates(m.toModel()); err != nil {
+		return fmt.Errorf("mqtt template: %w", err)
+	}
+	return nil
+}
+
+func (m *MQTTAction) toModel() model.MQTTAction {
+	return model.MQTTAction{
+		Broker:   m.Broker,
+		ClientID: m.ClientID,
+		Username: m.Username,
+		Password: m.Password,
+		TLS:      m.TLS.toModel(),
+		Topic:    m.Topic,
+		Message:  m.Message,
+		QoS:      m.QoS,
+		Retain:   m.Retain,
+	}
 }
 
 // Parse parses config file and returns config data.
+// Config files written in older versions are upgraded in memory.
 func Parse(configPath string) (*Config, error) {
-	f, err := os.Open(configPath)
+	b, err := readFile(configPath)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	cnf, err := io.ReadAll(f)
+	conf, err := decode(b)
 	if err != nil {
 		return nil, err
 	}
-	conf := &Config{}
-	if err := yaml.Unmarshal(cnf, conf); err != nil {
-		return nil, err
-	}
 	// set default running mode
 	if conf.Mode == "" {
 		conf.Mode = model.ModeResident
@@ -88,54 +671,367 @@ func Parse(configPath string) (*Config, error) {
 	return conf, nil
 }
 
-func (c *Config) validate() error {
-	if c.Version == "" {
-		return errors.New("version is required")
+// Migrate parses config file and writes it in the latest config version.
+func Migrate(configPath string, w io.Writer) error {
+	b, err := readFile(configPath)
+	if err != nil {
+		return err
+	}
+	conf, err := decode(b)
+	if err != nil {
+		return err
+	}
+	if err := conf.validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(conf); err != nil {
+		return err
+	}
+	// write version as number like "version: 2"
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "version" {
+			node.Content[i+1].Tag = "!!int"
+			node.Content[i+1].Style = 0
+		}
+	}
+	e := yaml.NewEncoder(w)
+	e.SetIndent(2)
+	if err := e.Encode(node); err != nil {
+		return err
+	}
+	return e.Close()
+}
+
+func readFile(configPath string) ([]byte, error) {
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func decode(b []byte) (*Config, error) {
+	header := struct {
+		Version string `yaml:"version"`
+	}{}
+	if err := yaml.Unmarshal(b, &header); err != nil {
+		return nil, err
+	}
+
+	var conf *Config
+	switch header.Version {
+	case "":
+		return nil, errors.New("version is required")
+	case "1":
+		v1 := &configV1{}
+		if err := yaml.Unmarshal(b, v1); err != nil {
+			return nil, err
+		}
+		conf = v1.migrate()
+	case Version:
+		conf = &Config{}
+		if err := yaml.Unmarshal(b, conf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config version: %s", header.Version)
 	}
+	conf.sourceVersion = header.Version
+	return conf, nil
+}
+
+func (c *Config) validate() error {
 	switch c.Mode {
 	case model.ModeResident:
 	case model.ModeOnDemand:
+	case model.ModeOneShot:
+	case model.ModeNone:
 	default:
 		return fmt.Errorf("unsupported running mode: %s", c.Mode)
 	}
 	if c.CalendarID == "" {
 		return errors.New("calendar_id is required")
 	}
-	if len(c.Handler) == 0 {
+	if c.Shutdown != nil && c.Shutdown.GraceWindow > c.Shutdown.Timeout && c.Shutdown.Timeout != 0 {
+		return errors.New("shutdown.grace_window should not be longer than shutdown.timeout")
+	}
+	if c.Scheduler != nil {
+		switch c.Scheduler.Type {
+		case model.SchedulerMemory, "":
+		case model.SchedulerPersistent:
+			if c.Scheduler.Path == "" {
+				return errors.New("scheduler.path is required for persistent scheduler")
+			}
+		default:
+			return fmt.Errorf("unsupported scheduler type: %s", c.Scheduler.Type)
+		}
+	}
+	if c.History != nil {
+		if c.History.Size < 0 {
+			return errors.New("history.size should not be negative")
+		}
+		switch c.History.Type {
+		case model.HistoryMemory, "":
+		case model.HistoryFile:
+			if c.History.Path == "" {
+				return errors.New("history.path is required for file history")
+			}
+		default:
+			return fmt.Errorf("unsupported history type: %s", c.History.Type)
+		}
+	}
+	if c.Launch != nil {
+		switch c.Launch.OnConflict {
+		case model.LaunchConflictJoin, model.LaunchConflictReject, "":
+		default:
+			return fmt.Errorf("unsupported launch.on_conflict: %s", c.Launch.OnConflict)
+		}
+		if c.Launch.RetryAfter < 0 {
+			return errors.New("launch.retry_after should not be negative")
+		}
+	}
+	if g := c.SyncGuard; g != nil {
+		if g.MaxUnregister < 0 {
+			return errors.New("sync_guard.max_unregister should not be negative")
+		}
+		if g.MaxUnregisterRatio < 0 || g.MaxUnregisterRatio > 1 {
+			return errors.New("sync_guard.max_unregister_ratio should be between 0 and 1")
+		}
+	}
+	if c.Concurrency != nil && (c.Concurrency.Actions < 0 || c.Concurrency.Tasks < 0) {
+		return errors.New("concurrency should not be negative")
+	}
+	if err := c.validateLeaderElection(); err != nil {
+		return err
+	}
+	if len(c.Handlers) == 0 {
 		return errors.New("handler should be defined one or more")
 	}
-	if len(c.Action) == 0 {
+	if len(c.Actions) == 0 {
 		return errors.New("action should be defined one or more")
 	}
-	for _, h := range c.Handler {
+
+	c.actionMap = make(map[model.ActionName]*Action, len(c.Actions))
+	for i := range c.Actions {
+		a := &c.Actions[i]
+		if a.Name == "" {
+			return errors.New("action name should not be empty")
+		}
+		if _, ok := c.actionMap[a.Name]; ok {
+			return fmt.Errorf("action (%s) is defined more than once", a.Name)
+		}
+		if err := c.validateAction(a); err != nil {
+			return fmt.Errorf("action (%s): %w", a.Name, err)
+		}
+		c.actionMap[a.Name] = a
+	}
+	for i := range c.Actions {
+		a := &c.Actions[i]
+		if err := c.validateDeadLetter(a); err != nil {
+			return fmt.Errorf("action (%s): %w", a.Name, err)
+		}
+	}
+
+	c.handlerMap = make(map[string]*Handler, len(c.Handlers))
+	for i := range c.Handlers {
+		h := &c.Handlers[i]
+		summary := strings.TrimSpace(h.Summary)
+		if summary == "" {
+			return errors.New("handler summary should not be empty")
+		}
+		if _, ok := c.handlerMap[summary]; ok {
+			return fmt.Errorf("handler (%s) is defined more than once", summary)
+		}
 		for _, action := range append(h.Start, h.End...) {
 			if action == "" {
 				return errors.New("action name should not be empty")
 			}
-			// TODO: validate action name
-			if _, ok := c.Action[action]; !ok {
+			if _, ok := c.actionMap[action]; !ok {
 				return fmt.Errorf("action (%s) is not defined", action)
 			}
 		}
+		c.handlerMap[summary] = h
 	}
-	for _, a := range c.Action {
-		if c.Mode == model.ModeOnDemand && a.Type != model.ActionTasks {
-			return fmt.Errorf("unsupported action type with ondemand running mode: %s", a.Type)
+	return nil
+}
+
+func (c *Config) validateAction(a *Action) error {
+	if (c.Mode == model.ModeOnDemand || c.Mode == model.ModeOneShot) && a.Type != model.ActionTasks {
+		return fmt.Errorf("unsupported action type with %s running mode: %s", c.Mode, a.Type)
+	}
+	switch a.Type {
+	case model.ActionHTTP:
+		if a.HTTP == nil {
+			return errors.New("http block is required")
 		}
-		switch a.Type {
-		case model.ActionHTTP:
-		case model.ActionPubSub:
-		case model.ActionTasks:
-		default:
-			return fmt.Errorf("unsupported action type: %s", a.Type)
+	case model.ActionPubSub:
+		if a.PubSub == nil {
+			return errors.New("pubsub block is required")
+		}
+	case model.ActionTasks:
+		if a.Tasks == nil {
+			return errors.New("tasks block is required")
+		}
+	case model.ActionSlack:
+		if a.Slack == nil {
+			return errors.New("slack block is required")
+		}
+		if err := a.Slack.validate(); err != nil {
+			return err
+		}
+	case model.ActionEmail:
+		if a.Email == nil {
+			return errors.New("email block is required")
+		}
+		if err := a.Email.validate(); err != nil {
+			return err
+		}
+	case model.ActionExec:
+		if a.Exec == nil {
+			return errors.New("exec block is required")
+		}
+		if err := a.Exec.validate(); err != nil {
+			return err
+		}
+	case model.ActionMQTT:
+		if a.MQTT == nil {
+			return errors.New("mqtt block is required")
+		}
+		if err := a.MQTT.validate(); err != nil {
+			return err
+		}
+	case model.ActionNATS:
+		if a.NATS == nil {
+			return errors.New("nats block is required")
+		}
+		if err := a.NATS.validate(); err != nil {
+			return err
+		}
+	case model.ActionAMQP:
+		if a.AMQP == nil {
+			return errors.New("amqp block is required")
+		}
+		if err := a.AMQP.validate(); err != nil {
+			return err
+		}
+	case model.ActionKafka:
+		if a.Kafka == nil {
+			return errors.New("kafka block is required")
+		}
+		if err := a.Kafka.validate(); err != nil {
+			return err
+		}
+	case model.ActionGRPC:
+		if a.GRPC == nil {
+			return errors.New("grpc block is required")
+		}
+		if err := a.GRPC.validate(); err != nil {
+			return err
+		}
+	case model.ActionPlugin:
+		if a.Plugin == nil {
+			return errors.New("plugin block is required")
+		}
+		if err := a.Plugin.validate(); err != nil {
+			return err
+		}
+	default:
+		if err := a.validateRegistered(); err != nil {
+			return err
+		}
+	}
+	if a.Type == model.ActionTasks && (a.Retry != nil || a.DeadLetter != nil) {
+		return errors.New("retry and dead_letter are not supported by tasks action, configure retry of the queue instead")
+	}
+	if a.Type == model.ActionPlugin && (a.Retry != nil || a.DeadLetter != nil) {
+		return errors.New("retry and dead_letter are not supported by plugin action, the plugin executes events by itself")
+	}
+	if a.Retry != nil {
+		if a.Retry.MaxAttempts < 0 {
+			return errors.New("retry.max_attempts should not be negative")
+		}
+		if a.Retry.Deadline < 0 {
+			return errors.New("retry.deadline should not be negative")
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateLeaderElection() error {
+	le := c.LeaderElection
+	if le == nil || le.Type == model.LeaderElectionNone {
+		return nil
+	}
+	if c.Mode != model.ModeResident && c.Mode != model.ModeOnDemand {
+		return fmt.Errorf("leader_election is unavailable with %s running mode", c.Mode)
+	}
+	switch le.Type {
+	case model.LeaderElectionFile:
+		if le.Path == "" {
+			return errors.New("leader_election.path is required for file leader election")
+		}
+	case model.LeaderElectionKubernetes:
+	default:
+		return fmt.Errorf("unsupported leader_election type: %s", le.Type)
+	}
+	if le.RetryPeriod < 0 || le.LeaseDuration < 0 {
+		return errors.New("leader_election durations should not be negative")
+	}
+	if le.LeaseDuration != 0 && le.RetryPeriod >= le.LeaseDuration {
+		return errors.New("leader_election.retry_period should be shorter than lease_duration")
+	}
+	return nil
+}
+
+func (c *Config) validateDeadLetter(a *Action) error {
+	if a.DeadLetter == nil {
+		return nil
+	}
+	switch a.DeadLetter.Type {
+	case model.DeadLetterFile:
+		if a.DeadLetter.Path == "" {
+			return errors.New("dead_letter.path is required for file sink")
 		}
+	case model.DeadLetterAction:
+		if a.DeadLetter.Action == a.Name {
+			return errors.New("dead_letter.action should not be the action itself")
+		}
+		target, ok := c.actionMap[a.DeadLetter.Action]
+		if !ok {
+			return fmt.Errorf("dead_letter.action (%s) is not defined", a.DeadLetter.Action)
+		}
+		// dead letters are scheduled apart from the events of the target,
+		// which is not supported by actions scheduling events by themselves
+		_, registered := registry.Lookup(target.Type)
+		if target.Type == model.ActionTasks || target.Type == model.ActionPlugin || registered {
+			return fmt.Errorf("dead_letter.action (%s) should not be %s action", a.DeadLetter.Action, target.Type)
+		}
+	case model.DeadLetterMemory:
+	default:
+		return fmt.Errorf("unsupported dead_letter type: %s", a.DeadLetter.Type)
 	}
 	return nil
 }
 
+// SetRunningMode overrides running mode and validates config again.
+func (c *Config) SetRunningMode(mode model.RunningMode) error {
+	c.Mode = mode
+	if err := c.validate(); err != nil {
+		return fmt.Errorf("validation error: %w", err)
+	}
+	return nil
+}
+
+// SourceVersion returns config version written in the config file.
+func (c *Config) SourceVersion() string {
+	return c.sourceVersion
+}
+
 // ActionNames returns action names from event schedule.
 func (c *Config) ActionNames(event model.ScheduleEvent) ([]model.ActionName, bool) {
-	eh, ok := c.Handler[strings.TrimSpace(event.Summary)]
+	eh, ok := c.handlerMap[strings.TrimSpace(event.Summary)]
 	if !ok {
 		return nil, false
 	}
@@ -148,29 +1044,75 @@ func (c *Config) ActionNames(event model.ScheduleEvent) ([]model.ActionName, boo
 	return nil, false
 }
 
+// MisfirePolicy returns misfire policy of event handler.
+func (c *Config) MisfirePolicy(event model.ScheduleEvent) model.MisfirePolicy {
+	eh, ok := c.handlerMap[strings.TrimSpace(event.Summary)]
+	if !ok {
+		return model.MisfirePolicy{Mode: model.MisfireSkip}
+	}
+	return eh.Misfire.toModel()
+}
+
 // ActionConfigMap returns action config map.
 func (c *Config) ActionConfigMap() map[model.ActionName]model.ActionConfig {
-	acm := make(map[model.ActionName]model.ActionConfig, len(c.Action))
-	for an, action := range c.Action {
-		acm[an] = c.toActionConfig(action, an)
+	acm := make(map[model.ActionName]model.ActionConfig, len(c.Actions))
+	for _, action := range c.Actions {
+		acm[action.Name] = c.toActionConfig(action)
 	}
 	return acm
 }
 
-func (c *Config) toActionConfig(a Action, an model.ActionName) model.ActionConfig {
+func (c *Config) toActionConfig(a Action) model.ActionConfig {
 	ac := model.ActionConfig{
-		Name:    an,
-		Type:    a.Type,
-		Payload: a.Payload,
+		Name:       a.Name,
+		Type:       a.Type,
+		Payload:    a.Payload,
+		Retry:      a.Retry.toModel(),
+		DeadLetter: a.DeadLetter.toModel(),
+		Params:     a.params,
 	}
 	switch ac.Type {
 	case model.ActionHTTP:
-		ac.HTTPRequestAction = model.HTTPRequestAction(a.HTTPRequestAction)
+		ac.HTTPRequestAction = model.HTTPRequestAction(*a.HTTP)
 	case model.ActionPubSub:
-		ac.CloudPubSubAction = model.CloudPubSubAction(a.CloudPubSubAction)
+		ac.CloudPubSubAction = model.CloudPubSubAction(*a.PubSub)
 	case model.ActionTasks:
-		ac.CloudTasksAction = model.CloudTasksAction(a.CloudTasksAction)
-		ac.HTTPRequestAction = model.HTTPRequestAction(a.HTTPRequestAction)
+		ac.CloudTasksAction = model.CloudTasksAction{
+			Location:            a.Tasks.Location,
+			Queue:               a.Tasks.Queue,
+			TaskIDPrefix:        a.Tasks.TaskIDPrefix,
+			ServiceAccountEmail: a.Tasks.ServiceAccountEmail,
+		}
+		ac.HTTPRequestAction = model.HTTPRequestAction(a.Tasks.HTTPRequestAction)
+	case model.ActionSlack:
+		ac.Slack = model.SlackAction(*a.Slack)
+	case model.ActionEmail:
+		ac.Email = a.Email.toModel()
+	case model.ActionMQTT:
+		ac.MQTT = a.MQTT.toModel()
+	case model.ActionNATS:
+		ac.NATS = a.NATS.toModel()
+	case model.ActionAMQP:
+		ac.AMQP = a.AMQP.toModel()
+	case model.ActionKafka:
+		ac.Kafka = a.Kafka.toModel()
+	case model.ActionGRPC:
+		ac.GRPC = a.GRPC.toModel()
+	case model.ActionPlugin:
+		ac.Plugin = model.PluginAction{
+			Path:    a.Plugin.Path,
+			Args:    a.Plugin.Args,
+			Options: a.Plugin.Options,
+			Timeout: time.Duration(a.Plugin.Timeout),
+		}
+	case model.ActionExec:
+		ac.Exec = model.ExecAction{
+			Command: a.Exec.Command,
+			Args:    a.Exec.Args,
+			Dir:     a.Exec.Dir,
+			Env:     a.Exec.Env,
+			Timeout: time.Duration(a.Exec.Timeout),
+		}
 	}
 	return ac
 }
@@ -185,7 +1127,155 @@ func (c *Config) SyncInterval() time.Duration {
 	if c.Interval == 0 {
 		return defaultInterval
 	}
-	return c.Interval
+	return time.Duration(c.Interval)
+}
+
+// WorkerConfig returns resident worker configuration.
+// max_consecutive_failures is 10 if it is not set, and negative value means the worker never gives up.
+func (c *Config) WorkerConfig() model.WorkerConfig {
+	wc := model.WorkerConfig{
+		MaxConsecutiveFailures: defaultMaxConsecutiveFailures,
+	}
+	if c.Worker == nil {
+		return wc
+	}
+	if c.Worker.MaxConsecutiveFailures != 0 {
+		wc.MaxConsecutiveFailures = c.Worker.MaxConsecutiveFailures
+	}
+	wc.Backoff = c.Worker.Backoff.toModel()
+	return wc
+}
+
+// SchedulerConfig returns scheduler configuration.
+func (c *Config) SchedulerConfig() model.SchedulerConfig {
+	if c.Scheduler == nil {
+		return model.SchedulerConfig{
+			Type:    model.SchedulerMemory,
+			Misfire: model.MisfirePolicy{Mode: model.MisfireSkip},
+		}
+	}
+	sc := model.SchedulerConfig{
+		Type:    c.Scheduler.Type,
+		Path:    c.Scheduler.Path,
+		Misfire: c.Scheduler.Misfire.toModel(),
+	}
+	if sc.Type == "" {
+		sc.Type = model.SchedulerMemory
+	}
+	return sc
+}
+
+// HistoryConfig returns execution history configuration.
+func (c *Config) HistoryConfig() model.HistoryConfig {
+	if c.History == nil {
+		return model.HistoryConfig{Type: model.HistoryMemory}
+	}
+	hc := model.HistoryConfig(*c.History)
+	if hc.Type == "" {
+		hc.Type = model.HistoryMemory
+	}
+	return hc
+}
+
+// LeaderElectionConfig returns leader election configuration.
+func (c *Config) LeaderElectionConfig() model.LeaderElectionConfig {
+	lc := model.LeaderElectionConfig{
+		Name:          defaultLeaseName,
+		LeaseDuration: defaultLeaseDuration,
+		RetryPeriod:   defaultLeaderRetryPeriod,
+	}
+	le := c.LeaderElection
+	if le == nil {
+		return lc
+	}
+	lc.Type = le.Type
+	lc.Path = le.Path
+	lc.Namespace = le.Namespace
+	lc.Identity = le.Identity
+	lc.APIServer = le.APIServer
+	if le.Name != "" {
+		lc.Name = le.Name
+	}
+	if le.LeaseDuration != 0 {
+		lc.LeaseDuration = time.Duration(le.LeaseDuration)
+	}
+	if le.RetryPeriod != 0 {
+		lc.RetryPeriod = time.Duration(le.RetryPeriod)
+	}
+	return lc
+}
+
+// LaunchConfig returns launch request configuration.
+func (c *Config) LaunchConfig() model.LaunchConfig {
+	lc := model.LaunchConfig{
+		OnConflict: model.LaunchConflictJoin,
+		RetryAfter: defaultLaunchRetryAfter,
+	}
+	if c.Launch == nil {
+		return lc
+	}
+	if c.Launch.OnConflict != "" {
+		lc.OnConflict = c.Launch.OnConflict
+	}
+	if c.Launch.RetryAfter != 0 {
+		lc.RetryAfter = time.Duration(c.Launch.RetryAfter)
+	}
+	return lc
+}
+
+// SyncGuardConfig returns configuration of safeguards against mass unregistration.
+func (c *Config) SyncGuardConfig() model.SyncGuardConfig {
+	if c.SyncGuard == nil {
+		return model.SyncGuardConfig{}
+	}
+	return model.SyncGuardConfig(*c.SyncGuard)
+}
+
+// ConcurrencyConfig returns configuration of concurrent requests on sync.
+func (c *Config) ConcurrencyConfig() model.ConcurrencyConfig {
+	cc := model.ConcurrencyConfig{
+		Actions: defaultActionConcurrency,
+		Tasks:   defaultTasksConcurrency,
+	}
+	if c.Concurrency == nil {
+		return cc
+	}
+	if c.Concurrency.Actions > 0 {
+		cc.Actions = c.Concurrency.Actions
+	}
+	if c.Concurrency.Tasks > 0 {
+		cc.Tasks = c.Concurrency.Tasks
+	}
+	return cc
+}
+
+// ShutdownTimeout returns timeout of graceful shutdown.
+func (c *Config) ShutdownTimeout() time.Duration {
+	if c.Shutdown == nil || c.Shutdown.Timeout == 0 {
+		return defaultShutdownTimeout
+	}
+	return time.Duration(c.Shutdown.Timeout)
+}
+
+// ShutdownGraceWindow returns grace window in which scheduled events are executed on shutdown.
+func (c *Config) ShutdownGraceWindow() time.Duration {
+	if c.Shutdown == nil {
+		return 0
+	}
+	return time.Duration(c.Shutdown.GraceWindow)
+}
+
+// AbandonedEventsFile returns path to write events abandoned on shutdown.
+func (c *Config) AbandonedEventsFile() string {
+	if c.Shutdown == nil {
+		return ""
+	}
+	return c.Shutdown.AbandonedEventsFile
+}
+
+// DryRunEnabled reports whether synchronizer only plans and never registers events.
+func (c *Config) DryRunEnabled() bool {
+	return c.DryRun
 }
 
 // Calendar returns google calendar id.
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// MisfirePolicy is policy for missed executions.
+// It is written as "skip", "always" or {fire_if_within: 5m}.
+type MisfirePolicy struct {
+	Mode         model.MisfireMode
+	FireIfWithin Duration
+}
+
+type misfireWindow struct {
+	FireIfWithin Duration `yaml:"fire_if_within"`
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (p *MisfirePolicy) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var mode model.MisfireMode
+		if err := node.Decode(&mode); err != nil {
+			return err
+		}
+		switch mode {
+		case model.MisfireSkip, model.MisfireAlways:
+		default:
+			return fmt.Errorf("unsupported misfire policy: %s", mode)
+		}
+		*p = MisfirePolicy{Mode: mode}
+		return nil
+	}
+	w := misfireWindow{}
+	if err := node.Decode(&w); err != nil {
+		return err
+	}
+	if w.FireIfWithin <= 0 {
+		return errors.New("misfire.fire_if_within should be positive duration")
+	}
+	*p = MisfirePolicy{Mode: model.MisfireFireIfWithin, FireIfWithin: w.FireIfWithin}
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (p MisfirePolicy) MarshalYAML() (interface{}, error) {
+	if p.Mode == model.MisfireFireIfWithin {
+		return misfireWindow{FireIfWithin: p.FireIfWithin}, nil
+	}
+	return string(p.Mode), nil
+}
+
+func (p *MisfirePolicy) toModel() model.MisfirePolicy {
+	if p == nil {
+		return model.MisfirePolicy{Mode: model.MisfireSkip}
+	}
+	return model.MisfirePolicy{
+		Mode:   p.Mode,
+		Within: time.Duration(p.FireIfWithin),
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// configV1 represents a config.yml of version 1.
+type configV1 struct {
+	Version    string                        `yaml:"version"`
+	Mode       model.RunningMode             `yaml:"mode"`
+	Interval   time.Duration                 `yaml:"interval"`
+	CalendarID string                        `yaml:"calendar_id"`
+	Handler    map[string]eventHandlerV1     `yaml:"handler"`
+	Action     map[model.ActionName]actionV1 `yaml:"action"`
+}
+
+type eventHandlerV1 struct {
+	Start []model.ActionName `yaml:"start"`
+	End   []model.ActionName `yaml:"end"`
+}
+
+type actionV1 struct {
+	Type               model.ActionType `yaml:"type"`
+	HTTPRequestAction  `yaml:",inline"`
+	CloudPubSubAction  `yaml:",inline"`
+	cloudTasksActionV1 `yaml:",inline"`
+	Payload            map[string]interface{} `yaml:"payload"`
+}
+
+type cloudTasksActionV1 struct {
+	Location            string `yaml:"location"`
+	Queue               string `yaml:"queue"`
+	TaskIDPrefix        string `yaml:"task_id_prefix"`
+	ServiceAccountEmail string `yaml:"service_account_email"`
+}
+
+// migrate converts v1 config to the latest version.
+// Handlers and actions are sorted by name to make the output stable.
+func (c *configV1) migrate() *Config {
+	conf := &Config{
+		Version:    Version,
+		Mode:       c.Mode,
+		Interval:   Duration(c.Interval),
+		CalendarID: c.CalendarID,
+		Handlers:   make([]Handler, 0, len(c.Handler)),
+		Actions:    make([]Action, 0, len(c.Action)),
+	}
+
+	summaries := make([]string, 0, len(c.Handler))
+	for summary := range c.Handler {
+		summaries = append(summaries, summary)
+	}
+	sort.Strings(summaries)
+	for _, summary := range summaries {
+		h := c.Handler[summary]
+		conf.Handlers = append(conf.Handlers, Handler{
+			Summary: summary,
+			Start:   h.Start,
+			End:     h.End,
+		})
+	}
+
+	names := make([]string, 0, len(c.Action))
+	for name := range c.Action {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		conf.Actions = append(conf.Actions, c.Action[model.ActionName(name)].migrate(model.ActionName(name)))
+	}
+	return conf
+}
+
+func (a actionV1) migrate(name model.ActionName) Action {
+	action := Action{
+		Name:    name,
+		Type:    a.Type,
+		Payload: a.Payload,
+	}
+	switch a.Type {
+	case model.ActionHTTP:
+		http := a.HTTPRequestAction
+		action.HTTP = &http
+	case model.ActionPubSub:
+		pubsub := a.CloudPubSubAction
+		action.PubSub = &pubsub
+	case model.ActionTasks:
+		action.Tasks = &CloudTasksAction{
+			Location:            a.Location,
+			Queue:               a.Queue,
+			TaskIDPrefix:        a.TaskIDPrefix,
+			ServiceAccountEmail: a.ServiceAccountEmail,
+			HTTPRequestAction:   a.HTTPRequestAction,
+		}
+	}
+	return action
+}
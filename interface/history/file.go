@@ -0,0 +1,134 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// File implements repository.History which appends records to a local file as JSON lines.
+// The file is compacted to the latest size records when it grows to twice of them.
+type File struct {
+	path string
+	size int
+	// lines is the number of records in the file, or -1 until the file is compacted first.
+	lines int
+	mu    sync.Mutex
+}
+
+// NewFile returns file history store which keeps size records.
+func NewFile(path string, size int) *File {
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &File{path: path, size: size, lines: -1}
+}
+
+// Record appends execution to the file.
+func (f *File) Record(e model.Execution) error {
+	d, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// compaction also drops a partial line left by crash, which the record would be appended to
+	if f.lines < 0 || f.lines >= 2*f.size {
+		if err := f.compact(); err != nil {
+			return err
+		}
+	}
+	fp, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fp.Write(append(d, '\n')); err != nil {
+		fp.Close()
+		return err
+	}
+	f.lines++
+	return fp.Close()
+}
+
+// List reads the file and lists the latest records in chronological order.
+func (f *File) List(q model.HistoryQuery) ([]model.Execution, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	return latest(records, q), nil
+}
+
+// compact rewrites the file with the latest records.
+func (f *File) compact() error {
+	records, err := f.read()
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, e := range records {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return err
+	}
+	f.lines = len(records)
+	return nil
+}
+
+// read reads the latest records of the file.
+// Broken lines, e.g. a partial line left by crash, are skipped.
+func (f *File) read() ([]model.Execution, error) {
+	fp, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []model.Execution{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	records := make([]model.Execution, 0)
+	r := bufio.NewReader(fp)
+	for line := 1; ; line++ {
+		d, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(d)) > 0 {
+			e := model.Execution{}
+			if err := json.Unmarshal(d, &e); err != nil {
+				log.Printf("[history] skip broken record (line %d): %v\n", line, err)
+			} else {
+				records = append(records, e)
+			}
+		}
+		if len(records) >= 2*f.size {
+			records = append(records[:0], records[len(records)-f.size:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(records) > f.size {
+		records = records[len(records)-f.size:]
+	}
+	return records, nil
+}
@@ -0,0 +1,36 @@
+package history
+
+import (
+	"fmt"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+const defaultSize = 1000
+
+// New returns history store from configuration.
+func New(cnf repository.Config) (repository.History, error) {
+	hc := cnf.HistoryConfig()
+	switch hc.Type {
+	case model.HistoryMemory, "":
+		return NewMemory(hc.Size), nil
+	case model.HistoryFile:
+		return NewFile(hc.Path, hc.Size), nil
+	}
+	return nil, fmt.Errorf("unsupported history type: %s", hc.Type)
+}
+
+// latest returns the latest records which match query in chronological order.
+func latest(records []model.Execution, q model.HistoryQuery) []model.Execution {
+	res := make([]model.Execution, 0)
+	for _, r := range records {
+		if q.Match(r) {
+			res = append(res, r)
+		}
+	}
+	if q.Limit > 0 && len(res) > q.Limit {
+		res = res[len(res)-q.Limit:]
+	}
+	return res
+}
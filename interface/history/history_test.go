@@ -0,0 +1,155 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	records := []model.Execution{
+		{ActionName: "a", EventID: "1", Outcome: model.ExecutionSucceeded},
+		{ActionName: "b", EventID: "2", Outcome: model.ExecutionFailed},
+		{ActionName: "a", EventID: "3", Outcome: model.ExecutionSucceeded},
+		{ActionName: "a", EventID: "4", Outcome: model.ExecutionSucceeded},
+	}
+	stores := map[string]func(t *testing.T) repository.History{
+		"memory": func(t *testing.T) repository.History {
+			return NewMemory(10)
+		},
+		"file": func(t *testing.T) repository.History {
+			return NewFile(filepath.Join(t.TempDir(), "history.jsonl"), 10)
+		},
+	}
+	tests := []struct {
+		name  string
+		query model.HistoryQuery
+		want  []model.Execution
+	}{
+		{
+			name:  "filter by action",
+			query: model.HistoryQuery{ActionName: "a"},
+			want:  []model.Execution{records[0], records[2], records[3]},
+		},
+		{
+			name:  "limit",
+			query: model.HistoryQuery{Limit: 2},
+			want:  []model.Execution{records[2], records[3]},
+		},
+		{
+			name:  "filter by action and limit",
+			query: model.HistoryQuery{ActionName: "b", Limit: 1},
+			want:  []model.Execution{records[1]},
+		},
+	}
+	for name, newStore := range stores {
+		name, newStore := name, newStore
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			h := newStore(t)
+			for _, r := range records {
+				if err := h.Record(r); err != nil {
+					t.Fatalf("err should be nil but got %+v", err)
+				}
+			}
+			for _, tt := range tests {
+				got, err := h.List(tt.query)
+				if err != nil {
+					t.Fatalf("err should be nil but got %+v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("%s:\nwant: %+v\n got: %+v", tt.name, tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMemory_ring(t *testing.T) {
+	t.Parallel()
+	m := NewMemory(3)
+	records := make([]model.Execution, 0, 5)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		r := model.Execution{ActionName: "a", EventID: id}
+		records = append(records, r)
+		if err := m.Record(r); err != nil {
+			t.Fatalf("err should be nil but got %+v", err)
+		}
+	}
+	got, err := m.List(model.HistoryQuery{})
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if want := records[2:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("\nwant: %+v\n got: %+v", want, got)
+	}
+}
+
+func TestFile_List_notExist(t *testing.T) {
+	t.Parallel()
+	got, err := NewFile(filepath.Join(t.TempDir(), "history.jsonl"), 0).List(model.HistoryQuery{})
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("want no records but got %+v", got)
+	}
+}
+
+func TestFile_brokenRecords(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	// a broken line and a partial line left by crash
+	data := `{"action_name":"a","event_id":"1"}` + "\n" + `{"action_name":` + "\n" + `{"action_name":"a","ev`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFile(path, 0)
+	long := model.Execution{ActionName: "a", EventID: "2", Error: strings.Repeat("x", 100*1024)}
+	if err := f.Record(long); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	got, err := f.List(model.HistoryQuery{})
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	want := []model.Execution{{ActionName: "a", EventID: "1"}, long}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %d records but got %d: %+v", len(want), len(got), got)
+	}
+}
+
+func TestFile_compact(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	f := NewFile(path, 2)
+	records := make([]model.Execution, 0, 10)
+	for i := 0; i < 10; i++ {
+		r := model.Execution{ActionName: "a", EventID: strconv.Itoa(i)}
+		if err := f.Record(r); err != nil {
+			t.Fatalf("err should be nil but got %+v", err)
+		}
+		records = append(records, r)
+	}
+	got, err := f.List(model.HistoryQuery{})
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if want := records[8:]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("\nwant: %+v\n got: %+v", want, got)
+	}
+	d, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(d), "\n"); lines > 4 {
+		t.Fatalf("file should be compacted but has %d lines", lines)
+	}
+}
@@ -0,0 +1,49 @@
+package history
+
+import (
+	"sync"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// Memory implements repository.History which keeps the latest records in a ring buffer.
+type Memory struct {
+	records []model.Execution
+	next    int
+	full    bool
+	mu      sync.RWMutex
+}
+
+// NewMemory returns in-memory history store which keeps size records.
+func NewMemory(size int) *Memory {
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &Memory{
+		records: make([]model.Execution, size),
+	}
+}
+
+// Record records execution.
+func (m *Memory) Record(e model.Execution) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[m.next] = e
+	m.next = (m.next + 1) % len(m.records)
+	if m.next == 0 {
+		m.full = true
+	}
+	return nil
+}
+
+// List lists the latest records in chronological order.
+func (m *Memory) List(q model.HistoryQuery) ([]model.Execution, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := make([]model.Execution, 0, len(m.records))
+	if m.full {
+		records = append(records, m.records[m.next:]...)
+	}
+	records = append(records, m.records[:m.next]...)
+	return latest(records, q), nil
+}
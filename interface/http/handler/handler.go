@@ -2,29 +2,41 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 
+	"github.com/ww24/calendar-notifier/domain/model"
 	"github.com/ww24/calendar-notifier/usecase"
 )
 
+// errInvalidLimit is returned when limit of history is not a non-negative integer.
+var errInvalidLimit = errors.New("limit should be a non-negative integer")
+
 // New returns http handler.
-func New(sync usecase.Synchronizer) http.Handler {
+func New(sync usecase.Synchronizer, dl usecase.DeadLetter, h usecase.History) http.Handler {
 	mux := http.NewServeMux()
-	svc := newService(sync)
+	svc := newService(sync, dl, h)
 	mux.HandleFunc("/", svc.defaultHandler)
 	mux.HandleFunc("/launch", svc.sync)
+	mux.HandleFunc("/deadletters", svc.deadLetters)
+	mux.HandleFunc("/history", svc.history)
 	return mux
 }
 
 type syncService struct {
-	syn usecase.Synchronizer
+	syn  usecase.Synchronizer
+	dl   usecase.DeadLetter
+	hist usecase.History
 }
 
-func newService(sync usecase.Synchronizer) *syncService {
+func newService(sync usecase.Synchronizer, dl usecase.DeadLetter, h usecase.History) *syncService {
 	return &syncService{
-		syn: sync,
+		syn:  sync,
+		dl:   dl,
+		hist: h,
 	}
 }
 
@@ -32,9 +44,11 @@ func (s *syncService) defaultHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
 		return
 	}
+	health := s.syn.Health()
 	res := map[string]interface{}{
-		"status": "ok",
+		"status": health.Status,
 		"mode":   s.syn.RunningMode(),
+		"health": health,
 	}
 	d, err := json.Marshal(res)
 	if err != nil {
@@ -42,6 +56,9 @@ func (s *syncService) defaultHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if health.Status == model.HealthFailed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	if _, err := w.Write(append(d, '\n')); err != nil {
 		sendError(w, r, err)
 		return
@@ -58,27 +75,137 @@ func (s *syncService) sync(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.syn.Sync(r.Context()); err != nil {
+	ctx := r.Context()
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); force {
+		ctx = usecase.WithForce(ctx)
+	}
+	report, err := s.syn.Sync(ctx)
+	var inProgress *usecase.SyncInProgressError
+	if errors.As(err, &inProgress) {
+		sendConflict(w, inProgress)
+		return
+	}
+	if err != nil && report == nil {
 		sendError(w, r, err)
 		return
 	}
 
-	res := map[string]interface{}{"status": "maybe ok"}
+	// report is returned even if some of actions failed
+	res := map[string]interface{}{
+		"status": "ok",
+		"report": report,
+	}
+	if report.DryRun {
+		res["status"] = "dry run"
+	}
+	if err != nil {
+		res["status"] = "failed"
+		res["error"] = err.Error()
+	}
 	d, err := json.Marshal(res)
 	if err != nil {
 		sendError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	if res["status"] == "failed" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 	if _, err := w.Write(append(d, '\n')); err != nil {
 		sendError(w, r, err)
 		return
 	}
 }
 
+func (s *syncService) deadLetters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		return
+	case http.MethodGet:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	res := map[string]interface{}{"dead_letters": s.dl.List()}
+	d, err := json.Marshal(res)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(d, '\n')); err != nil {
+		sendError(w, r, err)
+		return
+	}
+}
+
+func (s *syncService) history(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodOptions:
+		return
+	case http.MethodGet:
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := model.HistoryQuery{
+		ActionName: model.ActionName(r.URL.Query().Get("action")),
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			sendBadRequest(w, errInvalidLimit)
+			return
+		}
+		q.Limit = n
+	}
+	records, err := s.hist.List(q)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	res := map[string]interface{}{"history": records}
+	d, err := json.Marshal(res)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(d, '\n')); err != nil {
+		sendError(w, r, err)
+		return
+	}
+}
+
+// sendConflict tells the caller to retry after the sync in progress.
+func sendConflict(w http.ResponseWriter, err *usecase.SyncInProgressError) {
+	if err.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+	writeError(w, http.StatusConflict, err)
+}
+
+// sendBadRequest tells the caller that the request is invalid.
+func sendBadRequest(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusBadRequest, err)
+}
+
 func sendError(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, http.StatusInternalServerError, err)
+	_ = json.NewEncoder(os.Stderr).Encode(errorResponse{Error: err.Error()})
+}
+
+// errorResponse is response body of errors.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes err as JSON with status code.
+func writeError(w http.ResponseWriter, code int, err error) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusInternalServerError)
-	fmt.Fprintf(w, `{"error":"%s"}`+"\n", err.Error())
-	fmt.Fprintf(os.Stderr, `{"error":"%s"}`+"\n", err.Error())
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
 }
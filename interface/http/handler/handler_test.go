@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendBadRequest(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	sendBadRequest(w, errors.New(`invalid "limit" \ value`))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("want status %d but got %d", http.StatusBadRequest, w.Code)
+	}
+	res := errorResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("body should be JSON: %v: %s", err, w.Body)
+	}
+	if want := `invalid "limit" \ value`; res.Error != want {
+		t.Errorf("want %q but got %q", want, res.Error)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package leader
+
+import (
+	"context"
+	"errors"
+)
+
+// FileLock is not supported on this platform.
+type FileLock struct{}
+
+// NewFileLock returns file lock.
+func NewFileLock(string) *FileLock {
+	return &FileLock{}
+}
+
+// TryAcquire always fails because file lock is not supported on this platform.
+func (l *FileLock) TryAcquire(context.Context) (bool, error) {
+	return false, errors.New("file leader election is not supported on this platform")
+}
+
+// Release does nothing.
+func (l *FileLock) Release(context.Context) error {
+	return nil
+}
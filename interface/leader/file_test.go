@@ -0,0 +1,49 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package leader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	l1, l2 := NewFileLock(path), NewFileLock(path)
+
+	for _, tc := range []struct {
+		name string
+		lock *FileLock
+		want bool
+	}{
+		{name: "first replica acquires", lock: l1, want: true},
+		{name: "second replica waits", lock: l2, want: false},
+		{name: "first replica renews", lock: l1, want: true},
+	} {
+		got, err := tc.lock.TryAcquire(ctx)
+		if err != nil {
+			t.Fatalf("%s: err should be nil but got %+v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: want %t but got %t", tc.name, tc.want, got)
+		}
+	}
+
+	if err := l1.Release(ctx); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	got, err := l2.TryAcquire(ctx)
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if !got {
+		t.Fatal("second replica should take over after release")
+	}
+	if err := l2.Release(ctx); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	microTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+	requestTimeout    = 10 * time.Second
+)
+
+// KubernetesLease implements Lock with coordination.k8s.io/v1 Lease.
+// It talks to Kubernetes API server over HTTP with the service account token.
+type KubernetesLease struct {
+	cli           *http.Client
+	server        string
+	tokenFile     string
+	namespace     string
+	name          string
+	identity      string
+	leaseDuration time.Duration
+	now           func() time.Time
+	mu            sync.Mutex
+}
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+	LeaseTransitions     *int32  `json:"leaseTransitions,omitempty"`
+}
+
+func (s *leaseSpec) holder() string {
+	if s.HolderIdentity == nil {
+		return ""
+	}
+	return *s.HolderIdentity
+}
+
+// expired reports whether holder has not renewed the lease within its duration.
+func (s *leaseSpec) expired(now time.Time) bool {
+	if s.holder() == "" || s.RenewTime == nil || s.LeaseDurationSeconds == nil {
+		return true
+	}
+	renewTime, err := time.Parse(microTimeFormat, *s.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewTime.Add(time.Duration(*s.LeaseDurationSeconds) * time.Second))
+}
+
+// NewKubernetesLease returns Kubernetes Lease lock.
+// In-cluster service account is used if server is empty.
+func NewKubernetesLease(server, namespace, name, identity string, leaseDuration time.Duration) (*KubernetesLease, error) {
+	l := &KubernetesLease{
+		cli:           &http.Client{Timeout: requestTimeout},
+		server:        strings.TrimSuffix(server, "/"),
+		namespace:     namespace,
+		name:          name,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		now:           time.Now,
+	}
+	if l.server != "" {
+		if l.namespace == "" {
+			return nil, errors.New("namespace is required with api server")
+		}
+		return l, nil
+	}
+
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("api server is unknown, set api_server or run in Kubernetes cluster")
+	}
+	l.server = "https://" + net.JoinHostPort(host, port)
+	l.tokenFile = serviceAccountDir + "/token"
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("failed to load ca.crt of service account")
+	}
+	l.cli.Transport = &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+	}
+	if l.namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		l.namespace = strings.TrimSpace(string(ns))
+	}
+	return l, nil
+}
+
+func (l *KubernetesLease) url(withName bool) string {
+	u := fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", l.server, l.namespace)
+	if withName {
+		u += "/" + l.name
+	}
+	return u
+}
+
+func (l *KubernetesLease) do(ctx context.Context, method, url string, in, out *lease) (int, error) {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if l.tokenFile != "" {
+		// token is rotated by kubelet
+		token, err := os.ReadFile(l.tokenFile)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := l.cli.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// TryAcquire creates, takes over or renews the lease.
+// Lease is updated with resource version, so that only one replica wins on conflict.
+func (l *KubernetesLease) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := &lease{}
+	code, err := l.do(ctx, http.MethodGet, l.url(true), nil, current)
+	if err != nil {
+		return false, err
+	}
+	now := l.now()
+	switch code {
+	case http.StatusNotFound:
+		next := l.newLease(nil, now)
+		code, err := l.do(ctx, http.MethodPost, l.url(false), next, nil)
+		if err != nil {
+			return false, err
+		}
+		return l.result(code)
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("get lease: unexpected response status: %d", code)
+	}
+
+	if current.Spec.holder() != l.identity && !current.Spec.expired(now) {
+		return false, nil
+	}
+	next := l.newLease(current, now)
+	code, err = l.do(ctx, http.MethodPut, l.url(true), next, nil)
+	if err != nil {
+		return false, err
+	}
+	return l.result(code)
+}
+
+func (l *KubernetesLease) result(code int) (bool, error) {
+	switch {
+	case code/100 == 2:
+		return true, nil
+	case code == http.StatusConflict:
+		// the other replica has updated the lease
+		return false, nil
+	}
+	return false, fmt.Errorf("update lease: unexpected response status: %d", code)
+}
+
+func (l *KubernetesLease) newLease(current *lease, now time.Time) *lease {
+	identity := l.identity
+	duration := int32(l.leaseDuration / time.Second)
+	renewTime := now.UTC().Format(microTimeFormat)
+	next := &lease{
+		APIVersion: "coordination.k8s.io/v1",
+		Kind:       "Lease",
+		Metadata: leaseMetadata{
+			Name:      l.name,
+			Namespace: l.namespace,
+		},
+		Spec: leaseSpec{
+			HolderIdentity:       &identity,
+			LeaseDurationSeconds: &duration,
+			AcquireTime:          &renewTime,
+			RenewTime:            &renewTime,
+		},
+	}
+	var transitions int32
+	if current != nil {
+		next.Metadata.ResourceVersion = current.Metadata.ResourceVersion
+		if current.Spec.LeaseTransitions != nil {
+			transitions = *current.Spec.LeaseTransitions
+		}
+		if current.Spec.holder() == l.identity {
+			next.Spec.AcquireTime = current.Spec.AcquireTime
+		} else {
+			transitions++
+		}
+	}
+	next.Spec.LeaseTransitions = &transitions
+	return next
+}
+
+// Release clears holder of the lease if the process holds it.
+func (l *KubernetesLease) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	current := &lease{}
+	code, err := l.do(ctx, http.MethodGet, l.url(true), nil, current)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK || current.Spec.holder() != l.identity {
+		return nil
+	}
+	empty := ""
+	current.Spec.HolderIdentity = &empty
+	current.Spec.RenewTime = nil
+	code, err = l.do(ctx, http.MethodPut, l.url(true), current, nil)
+	if err != nil {
+		return err
+	}
+	if code/100 != 2 && code != http.StatusConflict {
+		return fmt.Errorf("release lease: unexpected response status: %d", code)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPIServer serves a lease with optimistic concurrency of resource version.
+type fakeAPIServer struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		if s.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(s.lease)
+	case http.MethodPost:
+		if s.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.store(w, r)
+	case http.MethodPut:
+		if s.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.store(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeAPIServer) store(w http.ResponseWriter, r *http.Request) {
+	l := &lease{}
+	if err := json.NewDecoder(r.Body).Decode(l); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if s.lease != nil && l.Metadata.ResourceVersion != s.lease.Metadata.ResourceVersion {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	s.version++
+	l.Metadata.ResourceVersion = strconv.Itoa(s.version)
+	s.lease = l
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+func TestKubernetesLease_TryAcquire(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	api := &fakeAPIServer{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	newLease := func(identity string) *KubernetesLease {
+		l, err := NewKubernetesLease(srv.URL, "default", "calendar-notifier", identity, 15*time.Second)
+		if err != nil {
+			t.Fatalf("err should be nil but got %+v", err)
+		}
+		l.now = func() time.Time { return now }
+		return l
+	}
+	l1, l2 := newLease("replica-1"), newLease("replica-2")
+
+	steps := []struct {
+		name    string
+		lock    *KubernetesLease
+		advance time.Duration
+		want    bool
+	}{
+		{name: "create lease", lock: l1, want: true},
+		{name: "held by the other", lock: l2, advance: 10 * time.Second, want: false},
+		{name: "renew lease", lock: l1, want: true},
+		{name: "not expired yet", lock: l2, advance: 10 * time.Second, want: false},
+		{name: "take over expired lease", lock: l2, advance: 10 * time.Second, want: true},
+		{name: "held by new leader", lock: l1, want: false},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		got, err := step.lock.TryAcquire(ctx)
+		if err != nil {
+			t.Fatalf("%s: err should be nil but got %+v", step.name, err)
+		}
+		if got != step.want {
+			t.Fatalf("%s: want %t but got %t", step.name, step.want, got)
+		}
+	}
+	if got := *api.lease.Spec.LeaseTransitions; got != 1 {
+		t.Fatalf("want 1 transition but got %d", got)
+	}
+
+	if err := l2.Release(ctx); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	got, err := l1.TryAcquire(ctx)
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if !got {
+		t.Fatal("lease should be acquired after release")
+	}
+}
+
+func TestKubernetesLease_conflict(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	api := &fakeAPIServer{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	l, err := NewKubernetesLease(srv.URL, "default", "calendar-notifier", "replica-1", 15*time.Second)
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if ok, err := l.TryAcquire(ctx); err != nil || !ok {
+		t.Fatalf("lease should be acquired: %t, %+v", ok, err)
+	}
+
+	// the other replica updates the lease between read and write
+	current := &lease{}
+	if _, err := l.do(ctx, http.MethodGet, l.url(true), nil, current); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	api.mu.Lock()
+	api.version++
+	api.lease.Metadata.ResourceVersion = strconv.Itoa(api.version)
+	api.mu.Unlock()
+	code, err := l.do(ctx, http.MethodPut, l.url(true), l.newLease(current, l.now()), nil)
+	if err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if ok, err := l.result(code); err != nil || ok {
+		t.Fatalf("update with stale resource version should lose: %t, %+v", ok, err)
+	}
+}
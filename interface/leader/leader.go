@@ -0,0 +1,176 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+const releaseTimeout = 5 * time.Second
+
+// Lock is lock which is held by the leader.
+type Lock interface {
+	// TryAcquire tries to acquire or renew the lock and reports whether it is held.
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release releases the lock so that followers take over promptly.
+	Release(ctx context.Context) error
+}
+
+// Elector implements repository.Leader which campaigns for leadership with lock.
+// It also implements repository.Lease which holds the lock during a sync in ondemand mode.
+type Elector struct {
+	// renewed is unix time in nanoseconds when the last successful renewal started.
+	// It is the first field to be 64-bit aligned for atomic operations.
+	renewed       int64
+	lock          Lock
+	leaseDuration time.Duration
+	retryPeriod   time.Duration
+	leader        int32
+	acquired      chan struct{}
+}
+
+// New returns leader elector from configuration.
+func New(cnf repository.Config) (*Elector, error) {
+	lc := cnf.LeaderElectionConfig()
+	switch lc.Type {
+	case model.LeaderElectionNone:
+		// there is no need to notify because the process is the leader from the start
+		e := NewElector(nil, 0, lc.RetryPeriod)
+		e.leader = 1
+		return e, nil
+	case model.LeaderElectionFile:
+		// file lock is held until it is released, so that leadership does not expire
+		return NewElector(NewFileLock(lc.Path), 0, lc.RetryPeriod), nil
+	case model.LeaderElectionKubernetes:
+		identity := lc.Identity
+		if identity == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return nil, err
+			}
+			identity = hostname
+		}
+		lock, err := NewKubernetesLease(lc.APIServer, lc.Namespace, lc.Name, identity, lc.LeaseDuration)
+		if err != nil {
+			return nil, err
+		}
+		return NewElector(lock, lc.LeaseDuration, lc.RetryPeriod), nil
+	}
+	return nil, fmt.Errorf("unsupported leader election type: %s", lc.Type)
+}
+
+// NewElector returns leader elector.
+// The process is always the leader if lock is nil.
+// Leadership is regarded as lost when it has not been renewed within 4/5 of leaseDuration,
+// so that the process steps down before followers take over. It never expires if leaseDuration is 0.
+func NewElector(lock Lock, leaseDuration, retryPeriod time.Duration) *Elector {
+	return &Elector{
+		lock:          lock,
+		leaseDuration: leaseDuration,
+		retryPeriod:   retryPeriod,
+		acquired:      make(chan struct{}, 1),
+	}
+}
+
+// IsLeader reports whether the process is the leader.
+func (e *Elector) IsLeader() bool {
+	if atomic.LoadInt32(&e.leader) != 1 {
+		return false
+	}
+	return e.leaseDuration == 0 || time.Now().Before(e.renewDeadline())
+}
+
+// renewDeadline returns time until which leadership is valid without renewal.
+func (e *Elector) renewDeadline() time.Time {
+	renewed := time.Unix(0, atomic.LoadInt64(&e.renewed))
+	return renewed.Add(e.leaseDuration - e.leaseDuration/5)
+}
+
+// Acquired notifies that the process has become the leader.
+func (e *Elector) Acquired() <-chan struct{} {
+	return e.acquired
+}
+
+func (e *Elector) setLeader(leader bool) {
+	var v int32
+	if leader {
+		v = 1
+	}
+	if old := atomic.SwapInt32(&e.leader, v); old == v {
+		return
+	}
+	if !leader {
+		log.Println("[leader] lost leadership")
+		return
+	}
+	log.Println("[leader] acquired leadership")
+	select {
+	case e.acquired <- struct{}{}:
+	default:
+	}
+}
+
+// TryAcquire tries to acquire the lock for a sync.
+// It always succeeds if lock is nil.
+func (e *Elector) TryAcquire(ctx context.Context) (bool, error) {
+	if e.lock == nil {
+		return true, nil
+	}
+	return e.lock.TryAcquire(ctx)
+}
+
+// Release releases the lock acquired by TryAcquire.
+func (e *Elector) Release(ctx context.Context) error {
+	if e.lock == nil {
+		return nil
+	}
+	return e.lock.Release(ctx)
+}
+
+// Run campaigns for leadership until ctx is done, then releases it.
+func (e *Elector) Run(ctx context.Context) error {
+	if e.lock == nil {
+		return nil
+	}
+	ticker := time.NewTicker(e.retryPeriod)
+	defer ticker.Stop()
+	for {
+		ok, err := e.tryAcquire(ctx)
+		if err != nil {
+			// step down because leadership may not be renewed
+			log.Println("[leader] acquire error:", err)
+		}
+		e.setLeader(ok && err == nil)
+
+		select {
+		case <-ctx.Done():
+			e.setLeader(false)
+			rctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+			defer cancel()
+			return e.lock.Release(rctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquire tries to acquire or renew the lock, and records the time of successful renewal.
+// Renewal is bounded by the renew deadline, because leadership is lost after it anyway.
+func (e *Elector) tryAcquire(ctx context.Context) (bool, error) {
+	start := time.Now()
+	if e.leaseDuration > 0 && e.IsLeader() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, e.renewDeadline())
+		defer cancel()
+	}
+	ok, err := e.lock.TryAcquire(ctx)
+	if ok && err == nil {
+		atomic.StoreInt64(&e.renewed, start.UnixNano())
+	}
+	return ok, err
+}
@@ -0,0 +1,145 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLock struct {
+	mu       sync.Mutex
+	results  []error
+	calls    int
+	released chan struct{}
+	// hang makes calls after results block until ctx is done.
+	hang bool
+}
+
+func (l *fakeLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	if l.hang && l.calls >= len(l.results) {
+		l.calls++
+		l.mu.Unlock()
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	defer l.mu.Unlock()
+	var err error
+	if l.calls < len(l.results) {
+		err = l.results[l.calls]
+	}
+	l.calls++
+	return err == nil, err
+}
+
+func (l *fakeLock) Release(context.Context) error {
+	close(l.released)
+	return nil
+}
+
+func TestElector_Run(t *testing.T) {
+	t.Parallel()
+	errAcquire := errors.New("acquire error")
+	lock := &fakeLock{
+		results:  []error{nil, errAcquire, nil},
+		released: make(chan struct{}),
+	}
+	e := NewElector(lock, 0, 10*time.Millisecond)
+	if e.IsLeader() {
+		t.Fatal("elector should not be the leader before campaign")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- e.Run(ctx) }()
+
+	// acquired, stepped down by error, then acquired again
+	for i := 0; i < 2; i++ {
+		select {
+		case <-e.Acquired():
+		case <-time.After(time.Second):
+			t.Fatalf("leadership should be acquired: %d", i)
+		}
+		if !e.IsLeader() {
+			t.Fatal("elector should be the leader")
+		}
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	select {
+	case <-lock.released:
+	default:
+		t.Fatal("lock should be released")
+	}
+	if e.IsLeader() {
+		t.Fatal("elector should not be the leader after run")
+	}
+}
+
+func TestElector_Run_renewTimeout(t *testing.T) {
+	t.Parallel()
+	lock := &fakeLock{
+		results:  []error{nil},
+		released: make(chan struct{}),
+		hang:     true,
+	}
+	leaseDuration := 200 * time.Millisecond
+	e := NewElector(lock, leaseDuration, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- e.Run(ctx) }()
+	select {
+	case <-e.Acquired():
+	case <-time.After(time.Second):
+		t.Fatal("leadership should be acquired")
+	}
+	acquired := time.Now()
+
+	// renewal hangs, and the elector steps down before the lease expires
+	for e.IsLeader() {
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Since(acquired); elapsed >= leaseDuration {
+		t.Fatalf("elector should step down before the lease expires: %s", elapsed)
+	}
+
+	// hanging renewal is canceled at the renew deadline, and the elector campaigns again
+	deadline := time.Now().Add(time.Second)
+	for {
+		lock.mu.Lock()
+		calls := lock.calls
+		lock.mu.Unlock()
+		if calls >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("renewal should be bounded by the renew deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+}
+
+func TestElector_Run_withoutLock(t *testing.T) {
+	t.Parallel()
+	e := NewElector(nil, 0, time.Second)
+	e.leader = 1
+	if err := e.Run(context.Background()); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	if !e.IsLeader() {
+		t.Fatal("elector should always be the leader without lock")
+	}
+}
@@ -0,0 +1,66 @@
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInitialInterval = 1 * time.Second
+	defaultMaxInterval     = 1 * time.Minute
+	defaultMultiplier      = 2.0
+	defaultJitter          = 0.2
+)
+
+var (
+	rnd   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	rndMu sync.Mutex
+)
+
+// Exponential is exponential backoff with jitter.
+// Zero value intervals and multiplier are replaced by default values.
+type Exponential struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Jitter is randomization factor in range [0, 1].
+	// Duration is randomized in range [d*(1-Jitter), d*(1+Jitter)].
+	Jitter float64
+}
+
+// Duration returns wait duration before n-th retry (n starts from 1).
+func (b Exponential) Duration(n int) time.Duration {
+	initial := b.InitialInterval
+	if initial <= 0 {
+		initial = defaultInitialInterval
+	}
+	max := b.MaxInterval
+	if max <= 0 {
+		max = defaultMaxInterval
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+	jitter := b.Jitter
+	if jitter < 0 || jitter > 1 {
+		jitter = defaultJitter
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	d := float64(initial) * math.Pow(multiplier, float64(n-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	if jitter > 0 {
+		rndMu.Lock()
+		r := rnd.Float64()
+		rndMu.Unlock()
+		d = d * (1 - jitter + 2*jitter*r)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,58 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponential_Duration(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		backoff Exponential
+		n       int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{
+			name:    "first retry",
+			backoff: Exponential{InitialInterval: time.Second, Jitter: 0},
+			n:       1,
+			min:     time.Second,
+			max:     time.Second,
+		},
+		{
+			name:    "exponential",
+			backoff: Exponential{InitialInterval: time.Second, Multiplier: 3, Jitter: 0},
+			n:       3,
+			min:     9 * time.Second,
+			max:     9 * time.Second,
+		},
+		{
+			name:    "capped by max interval",
+			backoff: Exponential{InitialInterval: time.Second, MaxInterval: 5 * time.Second, Jitter: 0},
+			n:       10,
+			min:     5 * time.Second,
+			max:     5 * time.Second,
+		},
+		{
+			name:    "with jitter",
+			backoff: Exponential{InitialInterval: 10 * time.Second, Jitter: 0.5},
+			n:       1,
+			min:     5 * time.Second,
+			max:     15 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			for i := 0; i < 100; i++ {
+				got := tt.backoff.Duration(tt.n)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("want [%s, %s] but got %s", tt.min, tt.max, got)
+				}
+			}
+		})
+	}
+}
@@ -1,121 +1,374 @@
 package scheduler
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"log"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	itime "github.com/ww24/calendar-notifier/internal/time"
 )
 
 const (
-	delimiter = ":"
+	delimiter      = ":"
+	defaultWorkers = 16
 )
 
 var (
 	// ErrAlreadyExists is error that scheduled event already exists.
 	ErrAlreadyExists = errors.New("already exists")
+	// ErrClosed is error that scheduler is already shut down.
+	ErrClosed = errors.New("scheduler closed")
 )
 
 // InMemory implements in-memory scheduler.
+// Pending events are kept in a min-heap of execution time, and a single
+// dispatcher goroutine hands due events to a bounded pool of workers.
 type InMemory struct {
-	parent   context.Context
-	eventMap sync.Map
+	parent  context.Context
+	handler Handler
+	clock   itime.Clock
+	workers int
+	gate    func() bool
+	// actions indexes events by action name and event ID.
+	actions  map[model.ActionName]map[string]*scheduledEvent
+	queue    eventQueue
+	closed   bool
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	wake     chan struct{}
+	tasks    chan *scheduledEvent
+	stop     chan struct{}
+	stopOnce sync.Once
+	// exited is closed when dispatcher exits.
+	exited chan struct{}
+}
+
+// Option is option of in-memory scheduler.
+type Option func(*InMemory)
+
+// WithClock sets clock of scheduler.
+func WithClock(clock itime.Clock) Option {
+	return func(s *InMemory) {
+		s.clock = clock
+	}
+}
+
+// WithWorkers sets the number of handlers which run concurrently.
+func WithWorkers(n int) Option {
+	return func(s *InMemory) {
+		if n > 0 {
+			s.workers = n
+		}
+	}
+}
+
+// WithGate sets gate which decides whether due events are executed.
+// Events which come due while gate is closed are skipped, e.g. on follower replicas.
+func WithGate(gate func() bool) Option {
+	return func(s *InMemory) {
+		s.gate = gate
+	}
 }
 
 // NewInMemory returns in-memory scheduler.
-func NewInMemory(ctx context.Context) *InMemory {
-	return &InMemory{
-		parent: ctx,
+func NewInMemory(ctx context.Context, handler Handler, opts ...Option) *InMemory {
+	s := &InMemory{
+		parent:  ctx,
+		handler: handler,
+		clock:   itime.SystemClock,
+		workers: defaultWorkers,
+		actions: make(map[model.ActionName]map[string]*scheduledEvent),
+		wake:    make(chan struct{}, 1),
+		tasks:   make(chan *scheduledEvent),
+		stop:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for i := 0; i < s.workers; i++ {
+		go s.work()
 	}
+	go s.dispatch()
+	return s
 }
 
 type scheduledEvent struct {
 	actionName model.ActionName
 	event      model.ScheduleEvent
-	handler    func(context.Context) error
-	cancel     context.CancelFunc
-	sync.Mutex
+	payload    []byte
+	// index is position in queue, or -1 if it is not queued.
+	index   int
+	started bool
 }
 
 func (e *scheduledEvent) key() string {
 	return prefix(e.actionName) + e.event.ID(delimiter)
 }
 
-func (e *scheduledEvent) register(ctx context.Context) {
-	e.Lock()
-	defer e.Unlock()
-	ctx, e.cancel = context.WithCancel(ctx)
-	timer := time.NewTimer(time.Until(e.event.ExecuteAt))
-	go func() {
-		defer timer.Stop()
-		select {
-		case <-ctx.Done():
-			log.Println("schedule event canceled:", e.key())
-		case <-timer.C:
-			if err := e.handler(ctx); err != nil {
-				log.Println("schedule event execute error:", err)
+func (e *scheduledEvent) task() *Task {
+	return &Task{
+		ActionName: e.actionName,
+		Event:      e.event,
+		Payload:    e.payload,
+	}
+}
+
+func prefix(an model.ActionName) string {
+	return string(an) + delimiter
+}
+
+// notify wakes up dispatcher to recalculate the next due time.
+func (s *InMemory) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch waits for the earliest event and sends due events to workers.
+func (s *InMemory) dispatch() {
+	defer close(s.exited)
+	for {
+		s.mu.Lock()
+		due := s.popDue(s.clock.Now())
+		var timer itime.Timer
+		if len(due) == 0 && len(s.queue) > 0 {
+			timer = s.clock.NewTimerAt(s.queue[0].event.ExecuteAt)
+		}
+		s.mu.Unlock()
+
+		for i, e := range due {
+			select {
+			case s.tasks <- e:
+			case <-s.stop:
+				s.requeue(due[i:])
+				return
+			case <-s.parent.Done():
+				s.requeue(due[i:])
+				s.cancelPending()
+				return
 			}
 		}
-	}()
+		if len(due) > 0 {
+			continue
+		}
+
+		var fire <-chan time.Time
+		if timer != nil {
+			fire = timer.C()
+		}
+		select {
+		case <-fire:
+		case <-s.wake:
+		case <-s.stop:
+			return
+		case <-s.parent.Done():
+			s.cancelPending()
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
 }
 
-func (e *scheduledEvent) unregister() {
-	e.Lock()
-	defer e.Unlock()
-	if e.cancel != nil {
-		e.cancel()
+// popDue pops events which have come due and marks them as started.
+func (s *InMemory) popDue(now time.Time) []*scheduledEvent {
+	due := make([]*scheduledEvent, 0)
+	for len(s.queue) > 0 && !s.queue[0].event.ExecuteAt.After(now) {
+		e := heap.Pop(&s.queue).(*scheduledEvent)
+		e.started = true
+		due = append(due, e)
 	}
-	e.cancel = nil
+	return due
 }
 
-func prefix(an model.ActionName) string {
-	return string(an) + delimiter
+// requeue pushes back events which have been popped but not handed to workers,
+// so that they are abandoned or canceled together with pending events.
+func (s *InMemory) requeue(events []*scheduledEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		if s.actions[e.actionName][e.event.ID(delimiter)] != e {
+			// unregistered while it was handed to workers
+			s.wg.Done()
+			continue
+		}
+		e.started = false
+		heap.Push(&s.queue, e)
+	}
+}
+
+// cancelPending cancels all of pending events.
+func (s *InMemory) cancelPending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) > 0 {
+		e := heap.Pop(&s.queue).(*scheduledEvent)
+		log.Println("schedule event canceled:", e.key())
+		s.wg.Done()
+	}
+}
+
+func (s *InMemory) work() {
+	for {
+		select {
+		case e := <-s.tasks:
+			s.execute(e)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *InMemory) execute(e *scheduledEvent) {
+	defer s.wg.Done()
+	if s.gate != nil && !s.gate() {
+		log.Println("schedule event skipped:", e.key())
+		return
+	}
+	if err := s.handler(s.parent, e.task()); err != nil {
+		log.Println("schedule event execute error:", err)
+	}
 }
 
 // List lists schedule events from in-memory scheduler.
+// Events which have already been executed are listed until they are unregistered,
+// so that they are not registered and executed again.
 func (s *InMemory) List(an model.ActionName) (model.ScheduleEvents, error) {
-	p := prefix(an)
-	res := make(model.ScheduleEvents, 0)
-	now := time.Now()
-	s.eventMap.Range(func(key, value interface{}) bool {
-		e := value.(*scheduledEvent)
-		if now.After(e.event.ExecuteAt) {
-			s.eventMap.Delete(key)
-			return true
-		}
-		if strings.HasPrefix(e.key(), p) {
-			res = append(res, e.event)
-		}
-		return true
-	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.actions[an]
+	res := make(model.ScheduleEvents, 0, len(events))
+	for _, e := range events {
+		res = append(res, e.event)
+	}
 	return res, nil
 }
 
 // Register registers schedule event to in-memory scheduler.
-func (s *InMemory) Register(an model.ActionName, event model.ScheduleEvent, handler func(context.Context) error) error {
-	e := &scheduledEvent{actionName: an, event: event, handler: handler}
-	if _, loaded := s.eventMap.LoadOrStore(e.key(), e); loaded {
+func (s *InMemory) Register(an model.ActionName, event model.ScheduleEvent, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	events, ok := s.actions[an]
+	if !ok {
+		events = make(map[string]*scheduledEvent)
+		s.actions[an] = events
+	}
+	id := event.ID(delimiter)
+	if _, ok := events[id]; ok {
 		return ErrAlreadyExists
 	}
-	e.register(s.parent)
+	e := &scheduledEvent{actionName: an, event: event, payload: payload}
+	events[id] = e
+	heap.Push(&s.queue, e)
+	s.wg.Add(1)
+	s.notify()
 	return nil
 }
 
+// restoreExecuted stores schedule event which has already been executed without scheduling it.
+func (s *InMemory) restoreExecuted(an model.ActionName, event model.ScheduleEvent, payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events, ok := s.actions[an]
+	if !ok {
+		events = make(map[string]*scheduledEvent)
+		s.actions[an] = events
+	}
+	id := event.ID(delimiter)
+	if _, ok := events[id]; ok {
+		return
+	}
+	events[id] = &scheduledEvent{actionName: an, event: event, payload: payload, index: -1, started: true}
+}
+
 // Unregister unregisters schedule events from in-memory scheduler.
+// Running handler is not interrupted.
 func (s *InMemory) Unregister(an model.ActionName, events ...model.ScheduleEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	registered := s.actions[an]
 	for _, event := range events {
-		e := &scheduledEvent{actionName: an, event: event}
-		v, ok := s.eventMap.Load(e.key())
+		id := event.ID(delimiter)
+		e, ok := registered[id]
 		if !ok {
 			continue
 		}
-		e = v.(*scheduledEvent)
-		e.unregister()
-		s.eventMap.Delete(e.key())
+		delete(registered, id)
+		if e.index >= 0 {
+			heap.Remove(&s.queue, e.index)
+			s.wg.Done()
+		}
+	}
+	if len(registered) == 0 {
+		delete(s.actions, an)
 	}
+	s.notify()
 	return nil
 }
+
+// abandon removes pending events which are scheduled after deadline from queue.
+// Abandoned events are still listed.
+func (s *InMemory) abandon(deadline time.Time) []model.ActionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	abandoned := make([]model.ActionEvent, 0)
+	remaining := s.queue[:0]
+	for _, e := range s.queue {
+		if !e.event.ExecuteAt.After(deadline) {
+			remaining = append(remaining, e)
+			continue
+		}
+		e.index = -1
+		abandoned = append(abandoned, model.ActionEvent{ActionName: e.actionName, Event: e.event})
+		s.wg.Done()
+	}
+	for i := len(remaining); i < len(s.queue); i++ {
+		s.queue[i] = nil
+	}
+	s.queue = remaining
+	for i, e := range s.queue {
+		e.index = i
+	}
+	heap.Init(&s.queue)
+	return abandoned
+}
+
+// Shutdown stops accepting new events and waits for running handlers and
+// handlers scheduled within grace window until ctx is done.
+// Pending events which could not be executed are canceled and returned.
+func (s *InMemory) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	abandoned := s.abandon(s.clock.Now().Add(grace))
+	s.notify()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	// abandon all of events which have not been started yet,
+	// including due events which dispatcher has pushed back on stop.
+	<-s.exited
+	abandoned = append(abandoned, s.abandon(time.Time{})...)
+	return abandoned
+}
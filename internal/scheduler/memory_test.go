@@ -4,12 +4,28 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	itime "github.com/ww24/calendar-notifier/internal/time"
 )
 
+func seed(t *testing.T, s *InMemory, events []*scheduledEvent) {
+	t.Helper()
+	for _, e := range events {
+		if e.started {
+			s.restoreExecuted(e.actionName, e.event, e.payload)
+			continue
+		}
+		if err := s.Register(e.actionName, e.event, e.payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestScheduler_List(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -77,10 +93,11 @@ func TestScheduler_List(t *testing.T) {
 			},
 		},
 		{
-			name: "old schedule event is removed",
+			name: "executed schedule event is kept until unregistered",
 			initialEvents: []*scheduledEvent{
 				{
 					actionName: "test",
+					started:    true,
 					event: model.ScheduleEvent{
 						ScheduleID:  "sid2",
 						Summary:     "test",
@@ -101,6 +118,13 @@ func TestScheduler_List(t *testing.T) {
 			},
 			actionName: "test",
 			want: model.ScheduleEvents{
+				{
+					ScheduleID:  "sid2",
+					Summary:     "test",
+					Description: "test schedule",
+					EventType:   model.Start,
+					ExecuteAt:   testTime.Add(-time.Hour),
+				},
 				{
 					ScheduleID:  "sid2",
 					Summary:     "test",
@@ -113,10 +137,8 @@ func TestScheduler_List(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewInMemory(ctx)
-			for _, e := range tt.initialEvents {
-				s.eventMap.Store(e.key(), e)
-			}
+			s := NewInMemory(ctx, nil)
+			seed(t, s, tt.initialEvents)
 
 			got, err := s.List(tt.actionName)
 			if err != nil {
@@ -134,7 +156,7 @@ func TestScheduler_Register(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
 	testTime := time.Now()
-	handler := func(context.Context) error { return nil }
+	handler := func(context.Context, *Task) error { return nil }
 	tests := []struct {
 		name          string
 		initialEvents []*scheduledEvent
@@ -155,7 +177,6 @@ func TestScheduler_Register(t *testing.T) {
 						EventType:   model.Start,
 						ExecuteAt:   testTime.Add(time.Hour),
 					},
-					handler: handler,
 				},
 			},
 			actionName: "test",
@@ -189,7 +210,6 @@ func TestScheduler_Register(t *testing.T) {
 						EventType:   model.Start,
 						ExecuteAt:   testTime.Add(time.Hour),
 					},
-					handler: handler,
 				},
 			},
 			actionName: "test",
@@ -221,22 +241,18 @@ func TestScheduler_Register(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewInMemory(ctx)
-			for _, e := range tt.initialEvents {
-				s.eventMap.Store(e.key(), e)
-			}
+			s := NewInMemory(ctx, handler)
+			seed(t, s, tt.initialEvents)
 
-			err := s.Register(tt.actionName, tt.event, handler)
+			err := s.Register(tt.actionName, tt.event, nil)
 			if !errors.Is(err, tt.wantErr) {
 				t.Fatalf("\nwant: %+v\n got: %+v", tt.wantErr, err)
 			}
 
-			got := make(model.ScheduleEvents, 0)
-			s.eventMap.Range(func(key, value interface{}) bool {
-				e := value.(*scheduledEvent)
-				got = append(got, e.event)
-				return true
-			})
+			got, err := s.List(tt.actionName)
+			if err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
 			got.SortByExecuteAtAsc()
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("\nwant: %#v\n got: %#v", tt.want, got)
@@ -249,7 +265,7 @@ func TestScheduler_Unregister(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
 	testTime := time.Now()
-	handler := func(context.Context) error { return nil }
+	handler := func(context.Context, *Task) error { return nil }
 	tests := []struct {
 		name          string
 		initialEvents []*scheduledEvent
@@ -284,7 +300,6 @@ func TestScheduler_Unregister(t *testing.T) {
 						EventType:   model.Start,
 						ExecuteAt:   testTime.Add(time.Hour),
 					},
-					handler: handler,
 				}, {
 					actionName: "test",
 					event: model.ScheduleEvent{
@@ -294,7 +309,6 @@ func TestScheduler_Unregister(t *testing.T) {
 						EventType:   model.End,
 						ExecuteAt:   testTime.Add(2 * time.Hour),
 					},
-					handler: handler,
 				},
 			},
 			actionName: "test",
@@ -329,7 +343,6 @@ func TestScheduler_Unregister(t *testing.T) {
 						EventType:   model.Start,
 						ExecuteAt:   testTime.Add(time.Hour),
 					},
-					handler: handler,
 				}, {
 					actionName: "test",
 					event: model.ScheduleEvent{
@@ -339,7 +352,6 @@ func TestScheduler_Unregister(t *testing.T) {
 						EventType:   model.End,
 						ExecuteAt:   testTime.Add(2 * time.Hour),
 					},
-					handler: handler,
 				},
 			},
 			actionName: "test",
@@ -364,22 +376,18 @@ func TestScheduler_Unregister(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := NewInMemory(ctx)
-			for _, e := range tt.initialEvents {
-				s.eventMap.Store(e.key(), e)
-			}
+			s := NewInMemory(ctx, handler)
+			seed(t, s, tt.initialEvents)
 
 			err := s.Unregister(tt.actionName, tt.events...)
 			if err != nil {
 				t.Fatalf("err should be nil but got %+v", err)
 			}
 
-			got := make(model.ScheduleEvents, 0)
-			s.eventMap.Range(func(key, value interface{}) bool {
-				e := value.(*scheduledEvent)
-				got = append(got, e.event)
-				return true
-			})
+			got, err := s.List(tt.actionName)
+			if err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
 			got.SortByExecuteAtAsc()
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("\nwant: %#v\n got: %#v", tt.want, got)
@@ -387,3 +395,227 @@ func TestScheduler_Unregister(t *testing.T) {
 		})
 	}
 }
+
+func TestScheduler_Shutdown(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		grace        time.Duration
+		wantExecuted bool
+		wantIndexes  []int
+	}{
+		{
+			name:         "execute imminent event",
+			grace:        time.Second,
+			wantExecuted: true,
+			wantIndexes:  []int{1},
+		},
+		{
+			name:         "abandon all events",
+			grace:        0,
+			wantExecuted: false,
+			wantIndexes:  []int{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testTime := time.Now()
+			events := []model.ScheduleEvent{
+				{
+					ScheduleID: "sid1",
+					EventType:  model.Start,
+					ExecuteAt:  testTime.Add(100 * time.Millisecond),
+				},
+				{
+					ScheduleID: "sid1",
+					EventType:  model.End,
+					ExecuteAt:  testTime.Add(time.Hour),
+				},
+			}
+			executed := make(chan struct{}, 1)
+			handler := func(context.Context, *Task) error {
+				executed <- struct{}{}
+				return nil
+			}
+			s := NewInMemory(context.Background(), handler)
+			for _, e := range events {
+				if err := s.Register("test", e, nil); err != nil {
+					t.Fatalf("err should be nil but got %+v", err)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			got := s.Shutdown(ctx, tt.grace)
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].Event.ExecuteAt.Before(got[j].Event.ExecuteAt)
+			})
+			want := make([]model.ActionEvent, 0, len(tt.wantIndexes))
+			for _, i := range tt.wantIndexes {
+				want = append(want, model.ActionEvent{ActionName: "test", Event: events[i]})
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("\nwant: %+v\n got: %+v", want, got)
+			}
+			if gotExecuted := len(executed) > 0; gotExecuted != tt.wantExecuted {
+				t.Fatalf("want executed %t but got %t", tt.wantExecuted, gotExecuted)
+			}
+			if err := s.Register("test", events[0], nil); !errors.Is(err, ErrClosed) {
+				t.Fatalf("want %+v but got %+v", ErrClosed, err)
+			}
+		})
+	}
+}
+
+func TestScheduler_dispatch(t *testing.T) {
+	t.Parallel()
+	testTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := itime.NewFakeClock(testTime)
+	executed := make(chan model.EventType, 3)
+	handler := func(_ context.Context, task *Task) error {
+		executed <- task.Event.EventType
+		return nil
+	}
+	s := NewInMemory(context.Background(), handler, WithClock(clock))
+	defer s.Shutdown(context.Background(), 0)
+	events := []model.ScheduleEvent{
+		{ScheduleID: "sid1", EventType: model.End, ExecuteAt: testTime.Add(2 * time.Minute)},
+		{ScheduleID: "sid1", EventType: model.Start, ExecuteAt: testTime.Add(time.Minute)},
+		{ScheduleID: "sid2", EventType: model.Start, ExecuteAt: testTime.Add(3 * time.Minute)},
+	}
+	for _, e := range events {
+		if err := s.Register("test", e, nil); err != nil {
+			t.Fatalf("err should be nil but got %+v", err)
+		}
+	}
+	if err := s.Unregister("test", events[2]); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+
+	for _, want := range []model.EventType{model.Start, model.End} {
+		clock.Advance(time.Minute)
+		select {
+		case got := <-executed:
+			if got != want {
+				t.Fatalf("want %s but got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s event should be executed", want)
+		}
+	}
+	clock.Advance(time.Hour)
+	select {
+	case got := <-executed:
+		t.Fatalf("unregistered event should not be executed but got %s", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestScheduler_workers(t *testing.T) {
+	t.Parallel()
+	testTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := itime.NewFakeClock(testTime)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := func(context.Context, *Task) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	s := NewInMemory(context.Background(), handler, WithClock(clock), WithWorkers(1))
+	for _, sid := range []string{"sid1", "sid2"} {
+		e := model.ScheduleEvent{ScheduleID: sid, EventType: model.Start, ExecuteAt: testTime}
+		if err := s.Register("test", e, nil); err != nil {
+			t.Fatalf("err should be nil but got %+v", err)
+		}
+	}
+
+	<-started
+	select {
+	case <-started:
+		t.Fatal("handlers should not run concurrently beyond the number of workers")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-started
+	if got := s.Shutdown(context.Background(), 0); len(got) != 0 {
+		t.Fatalf("want no abandoned events but got %+v", got)
+	}
+}
+
+func TestScheduler_Shutdown_dueEvents(t *testing.T) {
+	t.Parallel()
+	testTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := itime.NewFakeClock(testTime)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := func(context.Context, *Task) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	s := NewInMemory(context.Background(), handler, WithClock(clock), WithWorkers(1))
+	defer close(release)
+	events := []model.ScheduleEvent{
+		{ScheduleID: "sid1", EventType: model.Start, ExecuteAt: testTime},
+		{ScheduleID: "sid2", EventType: model.Start, ExecuteAt: testTime},
+	}
+	if err := s.Register("test", events[0], nil); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	<-started
+	// due event is popped by dispatcher while the worker is busy
+	if err := s.Register("test", events[1], nil); err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	got := s.Shutdown(ctx, 0)
+	want := []model.ActionEvent{{ActionName: "test", Event: events[1]}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("\nwant: %+v\n got: %+v", want, got)
+	}
+}
+
+func TestScheduler_gate(t *testing.T) {
+	t.Parallel()
+	testTime := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := itime.NewFakeClock(testTime)
+	executed := make(chan string, 2)
+	handler := func(_ context.Context, task *Task) error {
+		executed <- task.Event.ScheduleID
+		return nil
+	}
+	var open int32
+	gate := func() bool { return atomic.LoadInt32(&open) == 1 }
+	s := NewInMemory(context.Background(), handler, WithClock(clock), WithGate(gate))
+	defer s.Shutdown(context.Background(), 0)
+	for i, sid := range []string{"sid1", "sid2"} {
+		e := model.ScheduleEvent{ScheduleID: sid, EventType: model.Start, ExecuteAt: testTime.Add(time.Duration(i+1) * time.Minute)}
+		if err := s.Register("test", e, nil); err != nil {
+			t.Fatalf("err should be nil but got %+v", err)
+		}
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case got := <-executed:
+		t.Fatalf("event should not be executed while gate is closed but got %s", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	atomic.StoreInt32(&open, 1)
+	clock.Advance(time.Minute)
+	select {
+	case got := <-executed:
+		if got != "sid2" {
+			t.Fatalf("want sid2 but got %s", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event should be executed after gate is opened")
+	}
+}
@@ -0,0 +1,211 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+const (
+	storeOpenTimeout = 5 * time.Second
+)
+
+// Store is local embedded database shared by persistent schedulers.
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens database file of persistent scheduler.
+func OpenStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: storeOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("bolt.Open: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes database file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Persistent implements scheduler which persists scheduled tasks to local database.
+// Tasks are reloaded on boot, and tasks which came due while the process was
+// stopped are executed according to misfire policy.
+type Persistent struct {
+	mem     *InMemory
+	store   *Store
+	bucket  []byte
+	handler Handler
+	misfire model.MisfirePolicy
+	known   func(model.ActionName) bool
+}
+
+// NewPersistent returns persistent scheduler and restores tasks from store.
+// Tasks of actions which are not known are deleted on restore, since the actions have been removed from config.
+// All actions are known if known is nil.
+func NewPersistent(ctx context.Context, store *Store, namespace string, handler Handler, misfire model.MisfirePolicy, known func(model.ActionName) bool, opts ...Option) (*Persistent, error) {
+	s := &Persistent{
+		store:   store,
+		bucket:  []byte(namespace),
+		handler: handler,
+		misfire: misfire,
+		known:   known,
+	}
+	s.mem = NewInMemory(ctx, s.execute, opts...)
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(s.bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Persistent) restore() error {
+	tasks := make([]*Task, 0)
+	err := s.store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).ForEach(func(k, v []byte) error {
+			task := &Task{}
+			if err := json.Unmarshal(v, task); err != nil {
+				return fmt.Errorf("broken task (%s): %w", k, err)
+			}
+			tasks = append(tasks, task)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	now := s.mem.clock.Now()
+	for _, task := range tasks {
+		if s.known != nil && !s.known(task.ActionName) {
+			log.Printf("schedule event of removed action deleted: %s%s\n", prefix(task.ActionName), task.Event.ID(delimiter))
+			if err := s.delete(task.ActionName, task.Event); err != nil {
+				return err
+			}
+			continue
+		}
+		if task.Executed {
+			s.mem.restoreExecuted(task.ActionName, task.Event, task.Payload)
+			continue
+		}
+		if !s.misfire.ShouldFire(task.Event.ExecuteAt, now) {
+			log.Printf("schedule event missed: %s%s\n", prefix(task.ActionName), task.Event.ID(delimiter))
+			if err := s.delete(task.ActionName, task.Event); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.mem.Register(task.ActionName, task.Event, task.Payload); err != nil {
+			return err
+		}
+	}
+	if len(tasks) > 0 {
+		log.Printf("schedule events restored: %s: %d\n", s.bucket, len(tasks))
+	}
+	return nil
+}
+
+// execute executes task and marks it as executed.
+// Executed task is kept in the store until it is unregistered, so that it is
+// not executed again after reboot.
+func (s *Persistent) execute(ctx context.Context, task *Task) error {
+	err := s.handler(ctx, task)
+	if uerr := s.markExecuted(task); uerr != nil {
+		log.Println("schedule event update error:", uerr)
+	}
+	return err
+}
+
+func key(an model.ActionName, event model.ScheduleEvent) []byte {
+	return []byte(prefix(an) + event.ID(delimiter))
+}
+
+func (s *Persistent) put(task *Task) error {
+	v, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return s.store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put(key(task.ActionName, task.Event), v)
+	})
+}
+
+// markExecuted marks stored task as executed unless it has been unregistered.
+func (s *Persistent) markExecuted(task *Task) error {
+	executed := *task
+	executed.Executed = true
+	v, err := json.Marshal(&executed)
+	if err != nil {
+		return err
+	}
+	return s.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		k := key(task.ActionName, task.Event)
+		if b.Get(k) == nil {
+			return nil
+		}
+		return b.Put(k, v)
+	})
+}
+
+func (s *Persistent) delete(an model.ActionName, events ...model.ScheduleEvent) error {
+	return s.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		for _, event := range events {
+			if err := b.Delete(key(an, event)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// List lists schedule events from persistent scheduler.
+func (s *Persistent) List(an model.ActionName) (model.ScheduleEvents, error) {
+	return s.mem.List(an)
+}
+
+// Register registers schedule event to persistent scheduler.
+func (s *Persistent) Register(an model.ActionName, event model.ScheduleEvent, payload []byte) error {
+	task := &Task{ActionName: an, Event: event, Payload: payload}
+	if err := s.put(task); err != nil {
+		return err
+	}
+	if err := s.mem.Register(an, event, payload); err != nil {
+		if !errors.Is(err, ErrAlreadyExists) {
+			if derr := s.delete(an, event); derr != nil {
+				log.Println("schedule event delete error:", derr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Unregister unregisters schedule events from persistent scheduler.
+func (s *Persistent) Unregister(an model.ActionName, events ...model.ScheduleEvent) error {
+	if err := s.mem.Unregister(an, events...); err != nil {
+		return err
+	}
+	return s.delete(an, events...)
+}
+
+// Shutdown shuts down scheduler.
+// Abandoned events are kept in the store and restored on next boot.
+func (s *Persistent) Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent {
+	return s.mem.Shutdown(ctx, grace)
+}
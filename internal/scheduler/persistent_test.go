@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+func TestPersistent_restore(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		misfire   model.MisfirePolicy
+		executeAt time.Duration
+		executed  bool
+		removed   bool
+		wantFired bool
+		wantList  int
+		wantStore int
+	}{
+		{
+			name:      "restore pending event",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireSkip},
+			executeAt: time.Hour,
+			wantFired: false,
+			wantList:  1,
+			wantStore: 1,
+		},
+		{
+			name:      "skip overdue event",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireSkip},
+			executeAt: -time.Minute,
+			wantFired: false,
+			wantList:  0,
+			wantStore: 0,
+		},
+		{
+			name:      "fire overdue event within window",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireFireIfWithin, Within: 5 * time.Minute},
+			executeAt: -time.Minute,
+			wantFired: true,
+			wantList:  1,
+			wantStore: 1,
+		},
+		{
+			name:      "skip overdue event out of window",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireFireIfWithin, Within: 5 * time.Minute},
+			executeAt: -time.Hour,
+			wantFired: false,
+			wantList:  0,
+			wantStore: 0,
+		},
+		{
+			name:      "always fire overdue event",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireAlways},
+			executeAt: -24 * time.Hour,
+			wantFired: true,
+			wantList:  1,
+			wantStore: 1,
+		},
+		{
+			name:      "keep executed event without firing",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireAlways},
+			executeAt: -time.Minute,
+			executed:  true,
+			wantFired: false,
+			wantList:  1,
+			wantStore: 1,
+		},
+		{
+			name:      "delete event of removed action",
+			misfire:   model.MisfirePolicy{Mode: model.MisfireAlways},
+			executeAt: -time.Minute,
+			removed:   true,
+			wantFired: false,
+			wantList:  0,
+			wantStore: 0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "scheduler.db")
+			event := model.ScheduleEvent{
+				ScheduleID: "sid1",
+				Summary:    "test",
+				EventType:  model.Start,
+				ExecuteAt:  time.Now().Add(tt.executeAt).Truncate(time.Second),
+			}
+			payload := []byte(`{"key":"value"}`)
+
+			// persist event directly as if it was registered before restart
+			store, err := OpenStore(path)
+			if err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
+			s := &Persistent{store: store, bucket: []byte("test")}
+			if err := store.db.Update(func(tx *bolt.Tx) error {
+				_, err := tx.CreateBucketIfNotExists(s.bucket)
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.put(&Task{ActionName: "action", Event: event, Payload: payload, Executed: tt.executed}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err = OpenStore(path)
+			if err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
+			defer store.Close()
+			var (
+				mu    sync.Mutex
+				fired *Task
+			)
+			done := make(chan struct{})
+			handler := func(_ context.Context, task *Task) error {
+				mu.Lock()
+				defer mu.Unlock()
+				fired = task
+				close(done)
+				return nil
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			known := func(an model.ActionName) bool { return !tt.removed }
+			s, err = NewPersistent(ctx, store, "test", handler, tt.misfire, known)
+			if err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
+
+			if tt.wantFired {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("event should be fired")
+				}
+				mu.Lock()
+				if fired.ActionName != "action" || fired.Event.ID("") != event.ID("") ||
+					fired.Event.EventType != event.EventType || !reflect.DeepEqual(fired.Payload, payload) {
+					t.Fatalf("\nwant: %+v\n got: %+v", event, fired)
+				}
+				mu.Unlock()
+			} else {
+				select {
+				case <-done:
+					t.Fatal("event should not be fired")
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+
+			s.Shutdown(context.Background(), 0)
+			got, err := s.List("action")
+			if err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
+			if len(got) != tt.wantList {
+				t.Fatalf("want %d events but got %+v", tt.wantList, got)
+			}
+			var stored int
+			if err := store.db.View(func(tx *bolt.Tx) error {
+				stored = tx.Bucket(s.bucket).Stats().KeyN
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if stored != tt.wantStore {
+				t.Fatalf("want %d stored tasks but got %d", tt.wantStore, stored)
+			}
+		})
+	}
+}
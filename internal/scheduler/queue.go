@@ -0,0 +1,35 @@
+package scheduler
+
+// eventQueue is min-heap of scheduled events ordered by execution time.
+// It implements heap.Interface.
+type eventQueue []*scheduledEvent
+
+func (q eventQueue) Len() int {
+	return len(q)
+}
+
+func (q eventQueue) Less(i, j int) bool {
+	return q[i].event.ExecuteAt.Before(q[j].event.ExecuteAt)
+}
+
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *eventQueue) Push(x interface{}) {
+	e := x.(*scheduledEvent)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
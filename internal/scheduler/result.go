@@ -0,0 +1,28 @@
+package scheduler
+
+import "context"
+
+// Result is detail of execution which is reported by handler.
+type Result struct {
+	StatusCode int
+	MessageID  string
+	// ExitCode, Stdout and Stderr are reported by exec action.
+	ExitCode *int
+	Stdout   string
+	Stderr   string
+}
+
+type resultKey struct{}
+
+// WithResult returns context to which handler reports execution result.
+func WithResult(ctx context.Context) (context.Context, *Result) {
+	r := &Result{}
+	return context.WithValue(ctx, resultKey{}, r), r
+}
+
+// SetResult reports execution result if context accepts it.
+func SetResult(ctx context.Context, r Result) {
+	if res, ok := ctx.Value(resultKey{}).(*Result); ok {
+		*res = r
+	}
+}
@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"time"
 
 	"github.com/ww24/calendar-notifier/domain/model"
 )
@@ -9,6 +10,26 @@ import (
 // Scheduler represents event scheduler.
 type Scheduler interface {
 	List(model.ActionName) (model.ScheduleEvents, error)
-	Register(an model.ActionName, event model.ScheduleEvent, handler func(context.Context) error) error
+	Register(an model.ActionName, event model.ScheduleEvent, payload []byte) error
 	Unregister(model.ActionName, ...model.ScheduleEvent) error
+	// Shutdown waits for running handlers and handlers scheduled within grace window,
+	// then returns pending events which are abandoned.
+	Shutdown(ctx context.Context, grace time.Duration) []model.ActionEvent
 }
+
+// Task is scheduled unit of work.
+// Payload is serialized request which is interpreted by Handler.
+type Task struct {
+	ActionName model.ActionName    `json:"action_name"`
+	Event      model.ScheduleEvent `json:"event"`
+	Payload    []byte              `json:"payload"`
+	// Executed reports whether the task has already been executed.
+	Executed bool `json:"executed,omitempty"`
+}
+
+// Handler executes a task when the schedule event comes due.
+type Handler func(context.Context, *Task) error
+
+// Factory returns a scheduler which executes tasks with handler.
+// Namespace separates tasks of schedulers which share the same storage.
+type Factory func(namespace string, handler Handler) (Scheduler, error)
@@ -0,0 +1,44 @@
+package time
+
+import (
+	"time"
+)
+
+// Clock provides current time and timers.
+// It is replaced with FakeClock in tests.
+type Clock interface {
+	Now() time.Time
+	// NewTimerAt returns timer which fires at t.
+	NewTimerAt(t time.Time) Timer
+}
+
+// Timer represents single timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// SystemClock is clock of system time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimerAt(t time.Time) Timer {
+	return &systemTimer{t: time.NewTimer(time.Until(t))}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t *systemTimer) Stop() bool {
+	return t.t.Stop()
+}
@@ -0,0 +1,80 @@
+package time
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock implements Clock which advances only when Advance is called.
+type FakeClock struct {
+	now    time.Time
+	timers []*fakeTimer
+	mu     sync.Mutex
+}
+
+// NewFakeClock returns fake clock which starts at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns current time of fake clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimerAt returns timer which fires when fake clock reaches t.
+func (c *FakeClock) NewTimerAt(t time.Time) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ft := &fakeTimer{
+		clock: c,
+		at:    t,
+		c:     make(chan time.Time, 1),
+	}
+	if !t.After(c.now) {
+		ft.c <- c.now
+		return ft
+	}
+	c.timers = append(c.timers, ft)
+	return ft
+}
+
+// Advance advances fake clock and fires timers which come due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, ft := range c.timers {
+		if ft.at.After(c.now) {
+			timers = append(timers, ft)
+			continue
+		}
+		ft.c <- c.now
+	}
+	c.timers = timers
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, ft := range t.clock.timers {
+		if ft == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
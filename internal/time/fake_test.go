@@ -0,0 +1,48 @@
+package time
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+
+	due := c.NewTimerAt(start)
+	select {
+	case <-due.C():
+	default:
+		t.Fatal("timer which is already due should fire immediately")
+	}
+
+	timer := c.NewTimerAt(start.Add(time.Minute))
+	stopped := c.NewTimerAt(start.Add(time.Minute))
+	if !stopped.Stop() {
+		t.Fatal("active timer should be stopped")
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer should not fire before deadline")
+	default:
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case got := <-timer.C():
+		if want := start.Add(time.Minute); !got.Equal(want) {
+			t.Fatalf("want %s but got %s", want, got)
+		}
+	default:
+		t.Fatal("timer should fire at deadline")
+	}
+	select {
+	case <-stopped.C():
+		t.Fatal("stopped timer should not fire")
+	default:
+	}
+	if timer.Stop() {
+		t.Fatal("fired timer should not be active")
+	}
+}
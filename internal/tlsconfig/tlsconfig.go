@@ -0,0 +1,36 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+)
+
+// New returns TLS client configuration which loads CA certificate and client certificate.
+func New(tc model.TLSConfig) (*tls.Config, error) {
+	cnf := &tls.Config{
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+	if tc.CAFile != "" {
+		pem, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", tc.CAFile)
+		}
+		cnf.RootCAs = pool
+	}
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cnf.Certificates = []tls.Certificate{cert}
+	}
+	return cnf, nil
+}
@@ -78,6 +78,90 @@ func (mr *MockConfigMockRecorder) Calendar() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Calendar", reflect.TypeOf((*MockConfig)(nil).Calendar))
 }
 
+// ConcurrencyConfig mocks base method.
+func (m *MockConfig) ConcurrencyConfig() model.ConcurrencyConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConcurrencyConfig")
+	ret0, _ := ret[0].(model.ConcurrencyConfig)
+	return ret0
+}
+
+// ConcurrencyConfig indicates an expected call of ConcurrencyConfig.
+func (mr *MockConfigMockRecorder) ConcurrencyConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConcurrencyConfig", reflect.TypeOf((*MockConfig)(nil).ConcurrencyConfig))
+}
+
+// DryRunEnabled mocks base method.
+func (m *MockConfig) DryRunEnabled() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DryRunEnabled")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// DryRunEnabled indicates an expected call of DryRunEnabled.
+func (mr *MockConfigMockRecorder) DryRunEnabled() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DryRunEnabled", reflect.TypeOf((*MockConfig)(nil).DryRunEnabled))
+}
+
+// HistoryConfig mocks base method.
+func (m *MockConfig) HistoryConfig() model.HistoryConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistoryConfig")
+	ret0, _ := ret[0].(model.HistoryConfig)
+	return ret0
+}
+
+// HistoryConfig indicates an expected call of HistoryConfig.
+func (mr *MockConfigMockRecorder) HistoryConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryConfig", reflect.TypeOf((*MockConfig)(nil).HistoryConfig))
+}
+
+// LaunchConfig mocks base method.
+func (m *MockConfig) LaunchConfig() model.LaunchConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LaunchConfig")
+	ret0, _ := ret[0].(model.LaunchConfig)
+	return ret0
+}
+
+// LaunchConfig indicates an expected call of LaunchConfig.
+func (mr *MockConfigMockRecorder) LaunchConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LaunchConfig", reflect.TypeOf((*MockConfig)(nil).LaunchConfig))
+}
+
+// LeaderElectionConfig mocks base method.
+func (m *MockConfig) LeaderElectionConfig() model.LeaderElectionConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LeaderElectionConfig")
+	ret0, _ := ret[0].(model.LeaderElectionConfig)
+	return ret0
+}
+
+// LeaderElectionConfig indicates an expected call of LeaderElectionConfig.
+func (mr *MockConfigMockRecorder) LeaderElectionConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LeaderElectionConfig", reflect.TypeOf((*MockConfig)(nil).LeaderElectionConfig))
+}
+
+// MisfirePolicy mocks base method.
+func (m *MockConfig) MisfirePolicy(arg0 model.ScheduleEvent) model.MisfirePolicy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MisfirePolicy", arg0)
+	ret0, _ := ret[0].(model.MisfirePolicy)
+	return ret0
+}
+
+// MisfirePolicy indicates an expected call of MisfirePolicy.
+func (mr *MockConfigMockRecorder) MisfirePolicy(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MisfirePolicy", reflect.TypeOf((*MockConfig)(nil).MisfirePolicy), arg0)
+}
+
 // RunningMode mocks base method.
 func (m *MockConfig) RunningMode() model.RunningMode {
 	m.ctrl.T.Helper()
@@ -92,6 +176,34 @@ func (mr *MockConfigMockRecorder) RunningMode() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunningMode", reflect.TypeOf((*MockConfig)(nil).RunningMode))
 }
 
+// SchedulerConfig mocks base method.
+func (m *MockConfig) SchedulerConfig() model.SchedulerConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SchedulerConfig")
+	ret0, _ := ret[0].(model.SchedulerConfig)
+	return ret0
+}
+
+// SchedulerConfig indicates an expected call of SchedulerConfig.
+func (mr *MockConfigMockRecorder) SchedulerConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SchedulerConfig", reflect.TypeOf((*MockConfig)(nil).SchedulerConfig))
+}
+
+// SyncGuardConfig mocks base method.
+func (m *MockConfig) SyncGuardConfig() model.SyncGuardConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SyncGuardConfig")
+	ret0, _ := ret[0].(model.SyncGuardConfig)
+	return ret0
+}
+
+// SyncGuardConfig indicates an expected call of SyncGuardConfig.
+func (mr *MockConfigMockRecorder) SyncGuardConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncGuardConfig", reflect.TypeOf((*MockConfig)(nil).SyncGuardConfig))
+}
+
 // SyncInterval mocks base method.
 func (m *MockConfig) SyncInterval() time.Duration {
 	m.ctrl.T.Helper()
@@ -105,3 +217,17 @@ func (mr *MockConfigMockRecorder) SyncInterval() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncInterval", reflect.TypeOf((*MockConfig)(nil).SyncInterval))
 }
+
+// WorkerConfig mocks base method.
+func (m *MockConfig) WorkerConfig() model.WorkerConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WorkerConfig")
+	ret0, _ := ret[0].(model.WorkerConfig)
+	return ret0
+}
+
+// WorkerConfig indicates an expected call of WorkerConfig.
+func (mr *MockConfigMockRecorder) WorkerConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WorkerConfig", reflect.TypeOf((*MockConfig)(nil).WorkerConfig))
+}
@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: deadletter.go
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/ww24/calendar-notifier/domain/model"
+)
+
+// MockDeadLetterList is a mock of DeadLetterList interface.
+type MockDeadLetterList struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeadLetterListMockRecorder
+}
+
+// MockDeadLetterListMockRecorder is the mock recorder for MockDeadLetterList.
+type MockDeadLetterListMockRecorder struct {
+	mock *MockDeadLetterList
+}
+
+// NewMockDeadLetterList creates a new mock instance.
+func NewMockDeadLetterList(ctrl *gomock.Controller) *MockDeadLetterList {
+	mock := &MockDeadLetterList{ctrl: ctrl}
+	mock.recorder = &MockDeadLetterListMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeadLetterList) EXPECT() *MockDeadLetterListMockRecorder {
+	return m.recorder
+}
+
+// DeadLetters mocks base method.
+func (m *MockDeadLetterList) DeadLetters() []model.DeadLetter {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeadLetters")
+	ret0, _ := ret[0].([]model.DeadLetter)
+	return ret0
+}
+
+// DeadLetters indicates an expected call of DeadLetters.
+func (mr *MockDeadLetterListMockRecorder) DeadLetters() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeadLetters", reflect.TypeOf((*MockDeadLetterList)(nil).DeadLetters))
+}
@@ -0,0 +1,115 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: leader.go
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLeader is a mock of Leader interface.
+type MockLeader struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeaderMockRecorder
+}
+
+// MockLeaderMockRecorder is the mock recorder for MockLeader.
+type MockLeaderMockRecorder struct {
+	mock *MockLeader
+}
+
+// NewMockLeader creates a new mock instance.
+func NewMockLeader(ctrl *gomock.Controller) *MockLeader {
+	mock := &MockLeader{ctrl: ctrl}
+	mock.recorder = &MockLeaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLeader) EXPECT() *MockLeaderMockRecorder {
+	return m.recorder
+}
+
+// Acquired mocks base method.
+func (m *MockLeader) Acquired() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Acquired")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Acquired indicates an expected call of Acquired.
+func (mr *MockLeaderMockRecorder) Acquired() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquired", reflect.TypeOf((*MockLeader)(nil).Acquired))
+}
+
+// IsLeader mocks base method.
+func (m *MockLeader) IsLeader() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsLeader")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsLeader indicates an expected call of IsLeader.
+func (mr *MockLeaderMockRecorder) IsLeader() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsLeader", reflect.TypeOf((*MockLeader)(nil).IsLeader))
+}
+
+// MockLease is a mock of Lease interface.
+type MockLease struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeaseMockRecorder
+}
+
+// MockLeaseMockRecorder is the mock recorder for MockLease.
+type MockLeaseMockRecorder struct {
+	mock *MockLease
+}
+
+// NewMockLease creates a new mock instance.
+func NewMockLease(ctrl *gomock.Controller) *MockLease {
+	mock := &MockLease{ctrl: ctrl}
+	mock.recorder = &MockLeaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLease) EXPECT() *MockLeaseMockRecorder {
+	return m.recorder
+}
+
+// Release mocks base method.
+func (m *MockLease) Release(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockLeaseMockRecorder) Release(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockLease)(nil).Release), ctx)
+}
+
+// TryAcquire mocks base method.
+func (m *MockLease) TryAcquire(ctx context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TryAcquire", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TryAcquire indicates an expected call of TryAcquire.
+func (mr *MockLeaseMockRecorder) TryAcquire(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TryAcquire", reflect.TypeOf((*MockLease)(nil).TryAcquire), ctx)
+}
@@ -0,0 +1,25 @@
+package usecase
+
+import (
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+// DeadLetter is dead-letter service.
+type DeadLetter interface {
+	List() []model.DeadLetter
+}
+
+// NewDeadLetter returns dead-letter service.
+func NewDeadLetter(l repository.DeadLetterList) DeadLetter {
+	return &deadLetter{l: l}
+}
+
+type deadLetter struct {
+	l repository.DeadLetterList
+}
+
+// List lists schedule events which failed to be executed.
+func (d *deadLetter) List() []model.DeadLetter {
+	return d.l.DeadLetters()
+}
@@ -0,0 +1,25 @@
+package usecase
+
+import (
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
+)
+
+// History is execution history service.
+type History interface {
+	List(model.HistoryQuery) ([]model.Execution, error)
+}
+
+// NewHistory returns execution history service.
+func NewHistory(h repository.History) History {
+	return &history{h: h}
+}
+
+type history struct {
+	h repository.History
+}
+
+// List lists the latest executions which match query.
+func (h *history) List(q model.HistoryQuery) ([]model.Execution, error) {
+	return h.h.List(q)
+}
@@ -3,58 +3,254 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/repository"
 	"github.com/ww24/calendar-notifier/domain/service"
-	"github.com/ww24/calendar-notifier/internal/time"
+	"github.com/ww24/calendar-notifier/internal/backoff"
+	itime "github.com/ww24/calendar-notifier/internal/time"
 )
 
+const (
+	backoffJitter       = 0.2
+	leaseReleaseTimeout = 5 * time.Second
+	// launchSyncTimeout bounds sync by launch request, which is not canceled with the request.
+	launchSyncTimeout = 10 * time.Minute
+)
+
+// SyncInProgressError is error that launch request is rejected because the other sync is in progress.
+type SyncInProgressError struct {
+	// RetryAfter is hint for the caller when to retry.
+	RetryAfter time.Duration
+}
+
+func (e *SyncInProgressError) Error() string {
+	return "the other sync is in progress"
+}
+
 // Synchronizer is schedule synchronizer service.
 type Synchronizer interface {
 	RunningMode() model.RunningMode
-	Sync(context.Context) error
+	Health() model.Health
+	Sync(context.Context) (*model.SyncReport, error)
 	Worker(ctx context.Context) error
 }
 
+// WithForce returns context which overrides sync guard against mass unregistration.
+func WithForce(ctx context.Context) context.Context {
+	return service.WithForce(ctx)
+}
+
 // NewSynchronizer returns synchronizer.
-func NewSynchronizer(cnf service.Config, sync service.Synchronizer) Synchronizer {
+func NewSynchronizer(cnf service.Config, sync service.Synchronizer, leader repository.Leader, lease repository.Lease) Synchronizer {
 	return &synchronizer{
-		cnf:  cnf,
-		sync: sync,
+		cnf:    cnf,
+		sync:   sync,
+		leader: leader,
+		lease:  lease,
+		health: model.Health{Status: model.HealthOK},
 	}
 }
 
 type synchronizer struct {
-	cnf  service.Config
-	sync service.Synchronizer
+	cnf      service.Config
+	sync     service.Synchronizer
+	leader   repository.Leader
+	lease    repository.Lease
+	health   model.Health
+	healthMu sync.RWMutex
+	// inflight is sync which is in progress by launch request.
+	inflight   *syncCall
+	inflightMu sync.Mutex
+}
+
+// syncCall is sync in progress which is shared by concurrent launch requests.
+type syncCall struct {
+	done   chan struct{}
+	report *model.SyncReport
+	err    error
+}
+
+// wait waits for the sync until ctx is done.
+func (c *syncCall) wait(ctx context.Context) (*model.SyncReport, error) {
+	select {
+	case <-c.done:
+		return c.report, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
+// detachedContext keeps values of the parent context, but it is not canceled with the parent.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
 func (s *synchronizer) RunningMode() model.RunningMode {
 	return s.cnf.RunningMode()
 }
 
-func (s *synchronizer) Sync(ctx context.Context) error {
+// Health returns health status of the last synchronizations.
+func (s *synchronizer) Health() model.Health {
+	s.healthMu.RLock()
+	defer s.healthMu.RUnlock()
+	return s.health
+}
+
+// Sync synchronizes schedules once.
+// Concurrent calls are coalesced, so that only one reconciliation runs at a time:
+// a caller which arrives mid-sync shares the result in progress or is rejected
+// with SyncInProgressError according to launch configuration.
+// The sync is not canceled with the request of the caller which started it,
+// and each caller stops waiting for it when its own ctx is done.
+func (s *synchronizer) Sync(ctx context.Context) (*model.SyncReport, error) {
 	if s.cnf.RunningMode() == model.ModeResident {
-		return errors.New("launch handler is unavailable if running mode is resident")
+		return nil, errors.New("launch handler is unavailable if running mode is resident")
+	}
+	lc := s.cnf.LaunchConfig()
+
+	s.inflightMu.Lock()
+	if c := s.inflight; c != nil {
+		s.inflightMu.Unlock()
+		if lc.OnConflict == model.LaunchConflictReject {
+			return nil, &SyncInProgressError{RetryAfter: lc.RetryAfter}
+		}
+		return c.wait(ctx)
+	}
+	c := &syncCall{done: make(chan struct{})}
+	s.inflight = c
+	s.inflightMu.Unlock()
+
+	go s.run(detachedContext{ctx}, c, lc)
+	return c.wait(ctx)
+}
+
+// run runs the shared sync and notifies callers of the result.
+func (s *synchronizer) run(ctx context.Context, c *syncCall, lc model.LaunchConfig) {
+	ctx, cancel := context.WithTimeout(ctx, launchSyncTimeout)
+	defer cancel()
+	c.report, c.err = s.syncWithLease(ctx, lc)
+	s.inflightMu.Lock()
+	s.inflight = nil
+	s.inflightMu.Unlock()
+	close(c.done)
+}
+
+// syncWithLease syncs while holding the lease, so that other instances do not sync at the same time.
+func (s *synchronizer) syncWithLease(ctx context.Context, lc model.LaunchConfig) (*model.SyncReport, error) {
+	ok, err := s.lease.TryAcquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire sync lease: %w", err)
+	}
+	if !ok {
+		return nil, &SyncInProgressError{RetryAfter: lc.RetryAfter}
 	}
-	return s.sync.Sync(ctx)
+	defer func() {
+		rctx, cancel := context.WithTimeout(context.Background(), leaseReleaseTimeout)
+		defer cancel()
+		if err := s.lease.Release(rctx); err != nil {
+			log.Println("failed to release sync lease:", err)
+		}
+	}()
+
+	report, err := s.sync.Sync(ctx)
+	s.record(err)
+	return report, err
 }
 
 // Worker launchs worker and blocking until context canceled if running mode is resident.
+// Sync failures are retried with exponential backoff, and it gives up after
+// the configured number of consecutive failures.
+// Only the leader syncs, and it syncs immediately when it becomes the leader.
+// Retries stop when it loses the leadership.
 func (s *synchronizer) Worker(ctx context.Context) error {
 	if s.cnf.RunningMode() != model.ModeResident {
 		return nil
 	}
-	ticker := time.NewImmediateTicker(s.cnf.SyncInterval())
+	ticker := itime.NewImmediateTicker(s.cnf.SyncInterval())
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-s.leader.Acquired():
 		case <-ticker.C:
-			if err := s.sync.Sync(ctx); err != nil {
-				return err
-			}
 		}
+		if !s.leader.IsLeader() {
+			continue
+		}
+		if err := s.syncWithRetry(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *synchronizer) syncWithRetry(ctx context.Context) error {
+	wc := s.cnf.WorkerConfig()
+	b := backoff.Exponential{
+		InitialInterval: wc.Backoff.InitialInterval,
+		MaxInterval:     wc.Backoff.MaxInterval,
+		Multiplier:      wc.Backoff.Multiplier,
+		Jitter:          backoffJitter,
+	}
+	for retry := 1; ; retry++ {
+		_, err := s.sync.Sync(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		failures := s.record(err)
+		if err == nil {
+			return nil
+		}
+		log.Printf("sync failed (consecutive failures: %d): %+v\n", failures, err)
+		if wc.MaxConsecutiveFailures > 0 && failures >= wc.MaxConsecutiveFailures {
+			s.healthMu.Lock()
+			s.health.Status = model.HealthFailed
+			s.healthMu.Unlock()
+			return fmt.Errorf("sync failed %d times in a row: %w", failures, err)
+		}
+
+		wait := b.Duration(retry)
+		log.Println("retry sync after", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		// the new leader syncs instead
+		if !s.leader.IsLeader() {
+			log.Println("leadership is lost, stop retrying sync")
+			return nil
+		}
+	}
+}
+
+// record records sync result to health status and returns the number of consecutive failures.
+func (s *synchronizer) record(err error) int {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	now := time.Now()
+	if err == nil {
+		s.health = model.Health{
+			Status:        model.HealthOK,
+			LastSuccessAt: &now,
+			LastFailureAt: s.health.LastFailureAt,
+		}
+		return 0
 	}
+	s.health.Status = model.HealthDegraded
+	s.health.ConsecutiveFailures++
+	s.health.LastError = err.Error()
+	s.health.LastFailureAt = &now
+	return s.health.ConsecutiveFailures
 }
@@ -0,0 +1,347 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/ww24/calendar-notifier/domain/model"
+	"github.com/ww24/calendar-notifier/domain/service"
+	"github.com/ww24/calendar-notifier/mock/mock_repository"
+)
+
+// mocks is mocks of repositories which synchronizer depends on.
+// Calendar is stubbed by each test to drive sync, and no action is configured.
+type mocks struct {
+	ctrl   *gomock.Controller
+	cnf    *mock_repository.MockConfig
+	cal    *mock_repository.MockCalendar
+	leader *mock_repository.MockLeader
+	lease  *mock_repository.MockLease
+}
+
+func newMocks(t *testing.T, mode model.RunningMode) *mocks {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	m := &mocks{
+		ctrl:   ctrl,
+		cnf:    mock_repository.NewMockConfig(ctrl),
+		cal:    mock_repository.NewMockCalendar(ctrl),
+		leader: mock_repository.NewMockLeader(ctrl),
+		lease:  mock_repository.NewMockLease(ctrl),
+	}
+	m.cnf.EXPECT().RunningMode().Return(mode).AnyTimes()
+	m.cnf.EXPECT().SyncInterval().Return(time.Hour).AnyTimes()
+	m.cnf.EXPECT().ActionConfigMap().Return(map[model.ActionName]model.ActionConfig{}).AnyTimes()
+	m.cnf.EXPECT().ConcurrencyConfig().Return(model.ConcurrencyConfig{}).AnyTimes()
+	m.cnf.EXPECT().SyncGuardConfig().Return(model.SyncGuardConfig{}).AnyTimes()
+	m.cnf.EXPECT().DryRunEnabled().Return(false).AnyTimes()
+	return m
+}
+
+func (m *mocks) synchronizer() Synchronizer {
+	syn := service.NewSynchronizer(m.cnf, m.cal, mock_repository.NewMockActionConfigurator(m.ctrl))
+	return NewSynchronizer(service.NewConfig(m.cnf), syn, m.leader, m.lease)
+}
+
+// countCalendar stubs calendar to fail the first failures calls with err, and returns the number of calls.
+func (m *mocks) countCalendar(failures int32, err error) *int32 {
+	var calls int32
+	m.cal.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, time.Time, time.Time) (model.Schedules, error) {
+			if atomic.AddInt32(&calls, 1) <= failures {
+				return nil, err
+			}
+			return model.Schedules{}, nil
+		}).AnyTimes()
+	return &calls
+}
+
+func TestSynchronizer_Worker(t *testing.T) {
+	t.Parallel()
+	errSync := errors.New("sync error")
+	backoff := model.Backoff{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}
+	tests := []struct {
+		name       string
+		failures   int32
+		maxFailure int
+		wantErr    error
+		wantHealth model.HealthStatus
+	}{
+		{
+			name:       "recovered by retry",
+			failures:   2,
+			maxFailure: 3,
+			wantErr:    nil,
+			wantHealth: model.HealthOK,
+		},
+		{
+			name:       "give up",
+			failures:   3,
+			maxFailure: 3,
+			wantErr:    errSync,
+			wantHealth: model.HealthFailed,
+		},
+		{
+			name:       "never give up",
+			failures:   5,
+			maxFailure: -1,
+			wantErr:    nil,
+			wantHealth: model.HealthOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMocks(t, model.ModeResident)
+			m.cnf.EXPECT().WorkerConfig().Return(model.WorkerConfig{
+				MaxConsecutiveFailures: tt.maxFailure,
+				Backoff:                backoff,
+			}).AnyTimes()
+			m.leader.EXPECT().Acquired().Return(make(chan struct{})).AnyTimes()
+			m.leader.EXPECT().IsLeader().Return(true).AnyTimes()
+			m.countCalendar(tt.failures, errSync)
+			s := m.synchronizer()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errCh := make(chan error, 1)
+			go func() { errCh <- s.Worker(ctx) }()
+
+			var err error
+			if tt.wantErr == nil {
+				deadline := time.Now().Add(time.Second)
+				for s.Health().LastSuccessAt == nil && time.Now().Before(deadline) {
+					time.Sleep(time.Millisecond)
+				}
+				cancel()
+			}
+			err = <-errCh
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %+v but got %+v", tt.wantErr, err)
+			}
+			if got := s.Health().Status; got != tt.wantHealth {
+				t.Fatalf("want health %s but got %s", tt.wantHealth, got)
+			}
+		})
+	}
+}
+
+func TestSynchronizer_Worker_follower(t *testing.T) {
+	t.Parallel()
+	m := newMocks(t, model.ModeResident)
+	m.cnf.EXPECT().WorkerConfig().Return(model.WorkerConfig{}).AnyTimes()
+	var leader int32
+	acquired := make(chan struct{}, 1)
+	m.leader.EXPECT().Acquired().Return(acquired).AnyTimes()
+	m.leader.EXPECT().IsLeader().DoAndReturn(func() bool {
+		return atomic.LoadInt32(&leader) == 1
+	}).AnyTimes()
+	calls := m.countCalendar(0, nil)
+	s := m.synchronizer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Worker(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt32(calls); got != 0 {
+		t.Fatalf("follower must not sync but synced %d times", got)
+	}
+
+	// sync immediately after acquiring leadership
+	atomic.StoreInt32(&leader, 1)
+	acquired <- struct{}{}
+	deadline := time.Now().Add(time.Second)
+	for s.Health().LastSuccessAt == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("want 1 sync but got %d", got)
+	}
+}
+
+func TestSynchronizer_Worker_leadershipLost(t *testing.T) {
+	t.Parallel()
+	m := newMocks(t, model.ModeResident)
+	m.cnf.EXPECT().WorkerConfig().Return(model.WorkerConfig{
+		MaxConsecutiveFailures: 3,
+		Backoff:                model.Backoff{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond},
+	}).AnyTimes()
+	m.leader.EXPECT().Acquired().Return(make(chan struct{})).AnyTimes()
+	// leadership is lost after the first sync
+	var checks int32
+	m.leader.EXPECT().IsLeader().DoAndReturn(func() bool {
+		return atomic.AddInt32(&checks, 1) == 1
+	}).AnyTimes()
+	calls := m.countCalendar(10, errors.New("sync error"))
+	s := m.synchronizer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Worker(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("unexpected error: %+v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("want 1 sync but got %d", got)
+	}
+}
+
+func TestSynchronizer_Sync(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		onConflict model.LaunchConflictPolicy
+		wantCalls  int32
+		wantErr    bool
+	}{
+		{
+			name:       "join sync in progress",
+			onConflict: model.LaunchConflictJoin,
+			wantCalls:  1,
+			wantErr:    false,
+		},
+		{
+			name:       "reject while sync is in progress",
+			onConflict: model.LaunchConflictReject,
+			wantCalls:  1,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := newMocks(t, model.ModeOnDemand)
+			m.cnf.EXPECT().LaunchConfig().Return(model.LaunchConfig{OnConflict: tt.onConflict, RetryAfter: time.Minute}).AnyTimes()
+			m.lease.EXPECT().TryAcquire(gomock.Any()).Return(true, nil)
+			// lease is released once by the first caller
+			m.lease.EXPECT().Release(gomock.Any()).Return(nil)
+			var calls int32
+			started := make(chan struct{}, 1)
+			release := make(chan struct{})
+			m.cal.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(context.Context, time.Time, time.Time) (model.Schedules, error) {
+					atomic.AddInt32(&calls, 1)
+					started <- struct{}{}
+					<-release
+					return model.Schedules{}, nil
+				}).AnyTimes()
+			s := m.synchronizer()
+
+			ctx := context.Background()
+			first := make(chan error, 1)
+			go func() {
+				_, err := s.Sync(ctx)
+				first <- err
+			}()
+			<-started
+
+			second := make(chan error, 1)
+			go func() {
+				_, err := s.Sync(ctx)
+				second <- err
+			}()
+			if tt.wantErr {
+				var inProgress *SyncInProgressError
+				if err := <-second; !errors.As(err, &inProgress) {
+					t.Fatalf("want SyncInProgressError but got %+v", err)
+				} else if inProgress.RetryAfter != time.Minute {
+					t.Fatalf("want retry after %s but got %s", time.Minute, inProgress.RetryAfter)
+				}
+			} else {
+				// wait for the second caller to join the sync in progress
+				time.Sleep(50 * time.Millisecond)
+			}
+			close(release)
+			if err := <-first; err != nil {
+				t.Fatalf("err should be nil but got %+v", err)
+			}
+			if !tt.wantErr {
+				if err := <-second; err != nil {
+					t.Fatalf("err should be nil but got %+v", err)
+				}
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.wantCalls {
+				t.Fatalf("want %d calls but got %d", tt.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestSynchronizer_Sync_leaseHeld(t *testing.T) {
+	t.Parallel()
+	m := newMocks(t, model.ModeOnDemand)
+	m.cnf.EXPECT().LaunchConfig().Return(model.LaunchConfig{OnConflict: model.LaunchConflictJoin, RetryAfter: time.Minute}).AnyTimes()
+	m.lease.EXPECT().TryAcquire(gomock.Any()).Return(false, nil)
+	// calendar is not expected to be called without lease
+	s := m.synchronizer()
+
+	_, err := s.Sync(context.Background())
+	var inProgress *SyncInProgressError
+	if !errors.As(err, &inProgress) {
+		t.Fatalf("want SyncInProgressError but got %+v", err)
+	}
+}
+
+func TestSynchronizer_Sync_callerCanceled(t *testing.T) {
+	t.Parallel()
+	m := newMocks(t, model.ModeOnDemand)
+	m.cnf.EXPECT().LaunchConfig().Return(model.LaunchConfig{OnConflict: model.LaunchConflictJoin}).AnyTimes()
+	m.lease.EXPECT().TryAcquire(gomock.Any()).Return(true, nil)
+	released := make(chan struct{})
+	m.lease.EXPECT().Release(gomock.Any()).DoAndReturn(func(context.Context) error {
+		close(released)
+		return nil
+	})
+	started := make(chan struct{})
+	release := make(chan struct{})
+	m.cal.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _, _ time.Time) (model.Schedules, error) {
+			close(started)
+			<-release
+			return model.Schedules{}, ctx.Err()
+		})
+	s := m.synchronizer()
+
+	// the caller which started the sync goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := s.Sync(ctx)
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		_, err := s.Sync(context.Background())
+		second <- err
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want %v but got %+v", context.Canceled, err)
+	}
+
+	// the sync continues for the other caller which joined it
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("err should be nil but got %+v", err)
+	}
+	<-released
+}